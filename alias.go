@@ -6,8 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"reflect"
+	"sort"
 )
 
+type aliasesResponse map[string]struct {
+	Aliases map[string]aliasOptions `json:"aliases"`
+}
+
 func (c *esclient) existAlias(ctx context.Context, alias string) (bool, error) {
 	exists := c.client.Indices.ExistsAlias
 
@@ -23,10 +29,239 @@ func (c *esclient) existAlias(ctx context.Context, alias string) (bool, error) {
 	}
 	return false, nil
 }
-func (c *esclient) syncAlias(ctx context.Context, alias alias) error {
+
+// liveAlias returns options of the alias for each index the alias currently points to.
+func (c *esclient) liveAlias(ctx context.Context, alias string) (map[string]aliasOptions, error) {
+	get := c.client.Indices.GetAlias
+	res, err := get(
+		get.WithName(alias),
+		get.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get alias: %w", err)
+	}
+	if res.StatusCode == 404 {
+		return map[string]aliasOptions{}, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get alias: %w", err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get alias [alias=%v, statusCode=%v, res=%v]", alias, res.StatusCode, string(body))
+	}
+
+	got := make(aliasesResponse, 0)
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal alias json: %w", err)
+	}
+
+	live := make(map[string]aliasOptions, len(got))
+	for index, v := range got {
+		live[index] = v.Aliases[alias]
+	}
+	return live, nil
+}
+
+// diffAliasOptions returns sorted index names whose alias options differ from live.
+// It assumes that the alias points to the same indices as live.
+func diffAliasOptions(alias alias, live map[string]aliasOptions) ([]string, error) {
+	diffs := make([]string, 0)
+	for _, index := range alias.Indices {
+		ok, err := equalAliasOptions(alias.Options[index], live[index])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			diffs = append(diffs, index)
+		}
+	}
+	sort.Strings(diffs)
+	return diffs, nil
+}
+
+func equalAliasOptions(a, b aliasOptions) (bool, error) {
+	if a.IndexRouting != b.IndexRouting || a.SearchRouting != b.SearchRouting {
+		return false, nil
+	}
+	if boolValue(a.IsWriteIndex) != boolValue(b.IsWriteIndex) || boolValue(a.IsHidden) != boolValue(b.IsHidden) {
+		return false, nil
+	}
+
+	// normalize numbers decoded by yaml
+	fa, err := normalizeJSON(a.Filter)
+	if err != nil {
+		return false, fmt.Errorf("normalize filter: %w", err)
+	}
+	fb, err := normalizeJSON(b.Filter)
+	if err != nil {
+		return false, fmt.Errorf("normalize filter: %w", err)
+	}
+	return reflect.DeepEqual(fa, fb), nil
+}
+
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
+func normalizeJSON(v map[string]interface{}) (interface{}, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var n interface{}
+	if err := json.Unmarshal(b, &n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// indexAliases returns sorted alias names that point to the index.
+func (c *esclient) indexAliases(ctx context.Context, index string) ([]string, error) {
+	get := c.client.Indices.GetAlias
+	res, err := get(
+		get.WithIndex(index),
+		get.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get alias: %w", err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get alias: %w", err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get alias [index=%v, statusCode=%v, res=%v]", index, res.StatusCode, string(body))
+	}
+
+	got := make(aliasesResponse, 0)
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal alias json: %w", err)
+	}
+
+	aliases := make([]string, 0)
+	for name := range got[index].Aliases {
+		aliases = append(aliases, name)
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
+
+// aliasesWithPrefix returns sorted alias names that start with prefix.
+func (c *esclient) aliasesWithPrefix(ctx context.Context, prefix string) ([]string, error) {
+	get := c.client.Indices.GetAlias
+	res, err := get(
+		get.WithName(prefix+"*"),
+		get.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get alias: %w", err)
+	}
+	if res.StatusCode == 404 {
+		return []string{}, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get alias: %w", err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get alias [prefix=%v, statusCode=%v, res=%v]", prefix, res.StatusCode, string(body))
+	}
+
+	got := make(aliasesResponse, 0)
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal alias json: %w", err)
+	}
+
+	names := make(map[string]struct{}, 0)
+	for _, v := range got {
+		for name := range v.Aliases {
+			names[name] = struct{}{}
+		}
+	}
+	aliases := make([]string, 0, len(names))
+	for name := range names {
+		aliases = append(aliases, name)
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
+
+// undeclaredAliases returns live aliases matching prune prefix that are not declared in config.
+func (c *esclient) undeclaredAliases(ctx context.Context, conf config) ([]string, error) {
+	if c.pruneAliasPrefix == "" {
+		return []string{}, nil
+	}
+
+	live, err := c.aliasesWithPrefix(ctx, c.pruneAliasPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	declared := make(map[string]struct{}, len(conf.Aliases))
+	for _, alias := range conf.Aliases {
+		declared[alias.Name] = struct{}{}
+	}
+
+	undeclared := make([]string, 0)
+	for _, name := range live {
+		if _, ok := declared[name]; ok {
+			continue
+		}
+		undeclared = append(undeclared, name)
+	}
+	return undeclared, nil
+}
+
+// syncAliases applies all alias changes in config with a single aliases API request,
+// so that switching aliases is all-or-nothing.
+// Aliases with absent state and undeclared aliases for prune are removed in the same request.
+func (c *esclient) syncAliases(ctx context.Context, conf config) error {
 	i := c.client.Indices
 
-	query := aliasQuery(alias.Name, alias.Indices)
+	present := make([]alias, 0, len(conf.Aliases))
+	removes := make([]string, 0)
+	for _, alias := range conf.Aliases {
+		if alias.State != "absent" && alias.Rollover != nil {
+			ok, err := c.existAlias(ctx, alias.Name)
+			if err != nil {
+				return err
+			}
+			if ok {
+				c.logf("[skip] alias %v is managed by rollover\n", alias.Name)
+				continue
+			}
+			present = append(present, bootstrapAlias(alias))
+			continue
+		}
+		if alias.State != "absent" {
+			present = append(present, alias)
+			continue
+		}
+
+		ok, err := c.existAlias(ctx, alias.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			c.logf("[skip] alias %v already deleted\n", alias.Name)
+			continue
+		}
+		removes = append(removes, alias.Name)
+	}
+
+	undeclared, err := c.undeclaredAliases(ctx, conf)
+	if err != nil {
+		return fmt.Errorf("prune aliases: %w", err)
+	}
+
+	if len(present)+len(removes)+len(undeclared) == 0 {
+		return nil
+	}
+	query := aliasQuery(present, append(removes, undeclared...))
 
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(query); err != nil {
@@ -38,42 +273,75 @@ func (c *esclient) syncAlias(ctx context.Context, alias alias) error {
 		return fmt.Errorf("upsert aliases: %w", err)
 	}
 	if res.StatusCode != 200 {
+		for _, alias := range conf.Aliases {
+			c.logf("[fail] alias: %v\n", alias.Name)
+		}
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			return fmt.Errorf("failed to sync alias [alias=%v, statusCode=%v]", alias.Name, res.StatusCode)
+			return fmt.Errorf("failed to sync aliases [statusCode=%v]", res.StatusCode)
 		}
-		return fmt.Errorf("failed to sync alias [alias=%v, statusCode=%v, res=%v]", alias.Name, res.StatusCode, string(body))
+		return fmt.Errorf("failed to sync aliases [statusCode=%v, res=%v]", res.StatusCode, string(body))
 	}
-	return nil
-}
 
-func (c *esclient) syncAliases(ctx context.Context, conf config) error {
-	for _, alias := range conf.Aliases {
-		err := c.syncAlias(ctx, alias)
-		if err != nil {
-			c.logf("[fail] alias: %v\n", alias.Name)
-			return fmt.Errorf("sync alias: %w", err)
-		}
+	for _, alias := range present {
 		c.logf("[synced] alias: %v\n", alias.Name)
 	}
+	for _, name := range removes {
+		c.logf("[deleted] alias: %v\n", name)
+	}
+	for _, name := range undeclared {
+		c.logf("[pruned] alias: %v\n", name)
+	}
 	return nil
 }
 
-func aliasQuery(aliasName string, indices []string) map[string]interface{} {
-	Actions := make([]map[string]interface{}, 0, len(indices)+1)
+// aliasQuery builds actions of aliases API.
+// Each alias is removed from all indices and added to configured indices.
+func aliasQuery(aliases []alias, removes []string) map[string]interface{} {
+	Actions := make([]map[string]interface{}, 0)
 
-	Actions = append(Actions, map[string]interface{}{
-		"remove": map[string]interface{}{
-			"index": "*",
-			"alias": aliasName,
-		},
-	})
-
-	for _, index := range indices {
+	for _, alias := range aliases {
 		Actions = append(Actions, map[string]interface{}{
-			"add": map[string]interface{}{
+			"remove": map[string]interface{}{
+				"index": "*",
+				"alias": alias.Name,
+			},
+		})
+
+		for _, index := range alias.Indices {
+			add := map[string]interface{}{
 				"index": index,
-				"alias": aliasName,
+				"alias": alias.Name,
+			}
+
+			opts := alias.Options[index]
+			if len(opts.Filter) > 0 {
+				add["filter"] = opts.Filter
+			}
+			if opts.IndexRouting != "" {
+				add["index_routing"] = opts.IndexRouting
+			}
+			if opts.SearchRouting != "" {
+				add["search_routing"] = opts.SearchRouting
+			}
+			if opts.IsWriteIndex != nil {
+				add["is_write_index"] = *opts.IsWriteIndex
+			}
+			if opts.IsHidden != nil {
+				add["is_hidden"] = *opts.IsHidden
+			}
+
+			Actions = append(Actions, map[string]interface{}{
+				"add": add,
+			})
+		}
+	}
+
+	for _, name := range removes {
+		Actions = append(Actions, map[string]interface{}{
+			"remove": map[string]interface{}{
+				"index": "*",
+				"alias": name,
 			},
 		})
 	}
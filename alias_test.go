@@ -2,6 +2,7 @@ package eskeeper
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -77,6 +78,27 @@ func TestSyncAliases(t *testing.T) {
 				createTmpIndexHelper(tb, "test-v2")
 			},
 		},
+		{
+			name: "options",
+			conf: config{
+				Aliases: []alias{
+					{
+						Name:    "test-sync-filtered-alias",
+						Indices: []string{"test-v1", "test-v2"},
+						Options: map[string]aliasOptions{
+							"test-v1": {
+								Filter: map[string]interface{}{
+									"term": map[string]interface{}{"title": "a"},
+								},
+								IndexRouting:  "1",
+								SearchRouting: "1,2",
+							},
+						},
+					},
+				},
+			},
+			setup: func(tb testing.TB) {},
+		},
 		{
 			name: "switch",
 			conf: config{
@@ -109,3 +131,193 @@ func TestSyncAliases(t *testing.T) {
 		})
 	}
 }
+
+func TestSyncAliasesRemove(t *testing.T) {
+	tests := []struct {
+		name        string
+		conf        config
+		prefix      string
+		setup       func(tb testing.TB)
+		wantDeleted []string
+	}{
+		{
+			name: "absent",
+			conf: config{
+				Aliases: []alias{
+					{
+						Name:  "absent-alias-v1",
+						State: "absent",
+					},
+				},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "absent-alias-index-v1")
+				createTmpAliasHelper(tb, "absent-alias-v1", "absent-alias-index-v1")
+			},
+			wantDeleted: []string{"absent-alias-v1"},
+		},
+		{
+			name: "prune",
+			conf: config{
+				Aliases: []alias{
+					{
+						Name:    "prune-alias-keep",
+						Indices: []string{"prune-alias-index-v1"},
+					},
+				},
+			},
+			prefix: "prune-alias-",
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "prune-alias-index-v1")
+				createTmpAliasHelper(tb, "prune-alias-keep", "prune-alias-index-v1")
+				createTmpAliasHelper(tb, "prune-alias-stale", "prune-alias-index-v1")
+				createTmpAliasHelper(tb, "unmanaged-alias", "prune-alias-index-v1")
+			},
+			wantDeleted: []string{"prune-alias-stale"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es, err := newEsClient([]string{url}, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			es.pruneAliasPrefix = tt.prefix
+
+			ctx := context.Background()
+			tt.setup(t)
+			err = es.syncAliases(ctx, tt.conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.wantDeleted {
+				ok, err := es.existAlias(ctx, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok {
+					t.Errorf("alias %v still exists", name)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffAliasOptions(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name  string
+		alias alias
+		live  map[string]aliasOptions
+		want  []string
+	}{
+		{
+			name: "same",
+			alias: alias{
+				Name:    "tenant-a",
+				Indices: []string{"logs-v1", "logs-v2"},
+				Options: map[string]aliasOptions{
+					"logs-v2": {
+						Filter: map[string]interface{}{
+							"term": map[string]interface{}{"tenant": uint64(1)},
+						},
+						IsWriteIndex: &yes,
+					},
+				},
+			},
+			live: map[string]aliasOptions{
+				"logs-v1": {},
+				"logs-v2": {
+					Filter: map[string]interface{}{
+						"term": map[string]interface{}{"tenant": float64(1)},
+					},
+					IsWriteIndex: &yes,
+				},
+			},
+			want: []string{},
+		},
+		{
+			name: "changed-filter",
+			alias: alias{
+				Name:    "tenant-a",
+				Indices: []string{"logs-v1"},
+				Options: map[string]aliasOptions{
+					"logs-v1": {
+						Filter: map[string]interface{}{
+							"term": map[string]interface{}{"tenant": "a"},
+						},
+						SearchRouting: "1",
+					},
+				},
+			},
+			live: map[string]aliasOptions{
+				"logs-v1": {
+					Filter: map[string]interface{}{
+						"term": map[string]interface{}{"tenant": "b"},
+					},
+					SearchRouting: "1",
+				},
+			},
+			want: []string{"logs-v1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffAliasOptions(tt.alias, tt.live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want: %+v, got: %+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAliasQuery(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name    string
+		aliases []alias
+		removes []string
+		want    map[string]interface{}
+	}{
+		{
+			name: "multi",
+			aliases: []alias{
+				{
+					Name:    "alias1",
+					Indices: []string{"test-v1"},
+				},
+				{
+					Name:    "alias2",
+					Indices: []string{"test-v2"},
+					Options: map[string]aliasOptions{
+						"test-v2": {IsWriteIndex: &yes},
+					},
+				},
+			},
+			removes: []string{"alias3"},
+			want: map[string]interface{}{
+				"actions": []map[string]interface{}{
+					{"remove": map[string]interface{}{"index": "*", "alias": "alias1"}},
+					{"add": map[string]interface{}{"index": "test-v1", "alias": "alias1"}},
+					{"remove": map[string]interface{}{"index": "*", "alias": "alias2"}},
+					{"add": map[string]interface{}{"index": "test-v2", "alias": "alias2", "is_write_index": true}},
+					{"remove": map[string]interface{}{"index": "*", "alias": "alias3"}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := aliasQuery(tt.aliases, tt.removes)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+		})
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-
-	"github.com/Cside/jsondiff"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type indexConfigWithName map[string]indexConfig
@@ -43,183 +45,420 @@ func (c *esclient) index(ctx context.Context, index index) ([]byte, error) {
 	return b, nil
 }
 
-// func (c *esclient) settings(ctx context.Context, index index) ([]byte, error) {
-// 	getSettings := c.client.Indices.GetSettings
-// 	res, err := getSettings(
-// 		getSettings.WithIndex(index.Name),
-// 		getSettings.WithContext(ctx),
-// 	)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("get %v settings: %w", index.Name, err)
-// 	}
-// 	if res.StatusCode != 200 {
-// 		body, err := ioutil.ReadAll(res.Body)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("get %v settings: %w", index.Name, err)
-// 		}
-// 		return nil, fmt.Errorf("get %v settings: %v", index.Name, string(body))
-// 	}
-
-// 	b, err := ioutil.ReadAll(res.Body)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("get %v settings: %w", index.Name, err)
-// 	}
-// 	return b, nil
-// }
-
-// func (c *esclient) mapping(ctx context.Context, index index) ([]byte, error) {
-// 	getMapping := c.client.Indices.GetMapping
-// 	res, err := getMapping(
-// 		getMapping.WithIndex(index.Name),
-// 		getMapping.WithContext(ctx),
-// 	)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("get %v mapping: %w", index.Name, err)
-// 	}
-// 	if res.StatusCode != 200 {
-// 		body, err := ioutil.ReadAll(res.Body)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("get %v mapping: %w", index.Name, err)
-// 		}
-// 		return nil, fmt.Errorf("get %v mapping: %v", index.Name, string(body))
-// 	}
-
-// 	b, err := ioutil.ReadAll(res.Body)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("get %v settings: %w", index.Name, err)
-// 	}
-// 	fmt.Println(string(b))
-// 	return b, nil
-// }
-
-// func (c *esclient) equalSettings(ctx context.Context, index index, settingJSON []byte) (bool, error) {
-// 	m, err := c.settings(ctx, index)
-// 	if err != nil {
-// 		return false, fmt.Errorf("get setting: %w", err)
-// 	}
-
-// 	if diff := jsondiff.Diff(m, settingJSON); diff != "" {
-// 		// fmt.Printf("detect settings diff:\n%s", diff)
-// 		return false, nil
-// 	}
-// 	return true, nil
-// }
-
-func (c *esclient) equalMappingsProperties(ctx context.Context, index index, mappingsJSON []byte) (bool, error) {
+type indexSettingsWithName map[string]struct {
+	Settings map[string]interface{} `json:"settings"`
+	Defaults map[string]interface{} `json:"defaults"`
+}
+
+// staticSettings can only be set at index creation time or on a closed index.
+var staticSettings = map[string]struct{}{
+	"index.number_of_shards":                  struct{}{},
+	"index.number_of_routing_shards":          struct{}{},
+	"index.codec":                             struct{}{},
+	"index.routing_partition_size":            struct{}{},
+	"index.soft_deletes.enabled":              struct{}{},
+	"index.load_fixed_bitset_filters_eagerly": struct{}{},
+	"index.shard.check_on_startup":            struct{}{},
+}
+
+var staticSettingPrefixes = []string{
+	"index.analysis.",
+	"index.similarity.",
+	"index.sort.",
+}
+
+// settings returns live flat settings including default values.
+func (c *esclient) settings(ctx context.Context, index index) (map[string]interface{}, error) {
+	getSettings := c.client.Indices.GetSettings
+	res, err := getSettings(
+		getSettings.WithIndex(index.Name),
+		getSettings.WithContext(ctx),
+		getSettings.WithFlatSettings(true),
+		getSettings.WithIncludeDefaults(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get %v settings: %w", index.Name, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("get %v settings: %w", index.Name, err)
+		}
+		return nil, fmt.Errorf("get %v settings: %v", index.Name, string(body))
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get %v settings: %w", index.Name, err)
+	}
+
+	got := make(indexSettingsWithName, 0)
+	if err := json.Unmarshal(b, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal settings json: %w", err)
+	}
+	v, ok := got[index.Name]
+	if !ok {
+		return nil, errors.New("get settings response dose not contain index name field")
+	}
+
+	settings := make(map[string]interface{}, len(v.Settings)+len(v.Defaults))
+	for k, s := range v.Defaults {
+		settings[k] = s
+	}
+	for k, s := range v.Settings {
+		settings[k] = s
+	}
+	return settings, nil
+}
+
+// diffSettingsWithLive returns dynamic and static settings that differ from the live index.
+func (c *esclient) diffSettingsWithLive(ctx context.Context, index index, settings map[string]interface{}) (map[string]interface{}, []string, error) {
+	live, err := c.settings(ctx, index)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get settings: %w", err)
+	}
+	dynamic, static := diffSettings(live, settings)
+	return dynamic, static, nil
+}
+
+// diffSettings compares settings written in mapping file with live flat settings.
+// It returns changed dynamic settings as flat settings and sorted names of changed static settings.
+func diffSettings(live, want map[string]interface{}) (map[string]interface{}, []string) {
+	dynamic := make(map[string]interface{}, 0)
+	static := make([]string, 0)
+
+	for k, v := range flattenSettings(want) {
+		if l, ok := live[k]; ok && settingString(l) == settingString(v) {
+			continue
+		}
+		if isStaticSetting(k) {
+			static = append(static, k)
+			continue
+		}
+		dynamic[k] = v
+	}
+	sort.Strings(static)
+	return dynamic, static
+}
+
+func isStaticSetting(key string) bool {
+	if _, ok := staticSettings[key]; ok {
+		return true
+	}
+	for _, p := range staticSettingPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenSettings converts nested settings to flat settings prefixed "index.".
+func flattenSettings(settings map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{}, 0)
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			key := joinFieldPath(prefix, k)
+			if child, ok := v.(map[string]interface{}); ok {
+				walk(key, child)
+				continue
+			}
+			if !strings.HasPrefix(key, "index.") {
+				key = "index." + key
+			}
+			flat[key] = v
+		}
+	}
+	walk("", settings)
+	return flat
+}
+
+// settingString normalizes setting value because Elasticsearch returns all values as string.
+func settingString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(s)
+	case []interface{}:
+		values := make([]string, 0, len(s))
+		for _, e := range s {
+			values = append(values, settingString(e))
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+// mappingDefaults are values Elasticsearch omits from get mapping response.
+var mappingDefaults = map[string]interface{}{
+	"dynamic":               true,
+	"enabled":               true,
+	"index":                 true,
+	"doc_values":            true,
+	"store":                 false,
+	"eager_global_ordinals": false,
+	"ignore_malformed":      false,
+}
+
+func readIndexConfig(path string) (*indexConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("open mapping file: %w", err)
+	}
+
+	config := &indexConfig{}
+	err = json.Unmarshal(b, config)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal mapping json: %w", err)
+	}
+	return config, nil
+}
+
+// readIndexMapping reads mapping file of index, and overrides settings with settings of index.
+func readIndexMapping(index index) (*indexConfig, error) {
+	config := &indexConfig{}
+	if index.Mapping != "" {
+		c, err := readIndexConfig(index.Mapping)
+		if err != nil {
+			return nil, err
+		}
+		config = c
+	}
+	if len(index.Settings) > 0 {
+		settings := flattenSettings(config.Settings)
+		for k, v := range flattenSettings(index.Settings) {
+			settings[k] = v
+		}
+		config.Settings = settings
+	}
+	return config, nil
+}
+
+// hasIndexConfig reports whether index declares mappings or settings.
+func hasIndexConfig(index index) bool {
+	return index.Mapping != "" || len(index.Settings) > 0
+}
+
+func (c *esclient) liveIndexConfig(ctx context.Context, index index) (indexConfig, error) {
 	res, err := c.index(ctx, index)
 	if err != nil {
-		return false, fmt.Errorf("get mappings: %w", err)
+		return indexConfig{}, err
 	}
 
 	gotConf := make(indexConfigWithName, 0)
 
 	err = json.Unmarshal(res, &gotConf)
 	if err != nil {
-		return false, fmt.Errorf("unmarshal mapping json: %w", err)
+		return indexConfig{}, fmt.Errorf("unmarshal mapping json: %w", err)
 	}
 
 	v, ok := gotConf[index.Name]
 	if !ok {
-		return false, errors.New("get index response dose not contain index name field")
+		return indexConfig{}, errors.New("get index response dose not contain index name field")
+	}
+	return v, nil
+}
+
+// diffMappingsProperties returns fields in mappings that differ from the live index.
+func (c *esclient) diffMappingsProperties(ctx context.Context, index index, mappings map[string]interface{}) ([]string, error) {
+	live, err := c.liveIndexConfig(ctx, index)
+	if err != nil {
+		return nil, fmt.Errorf("get mappings: %w", err)
 	}
+	return diffMappings(live.Mappings, mappings)
+}
 
-	b, err := json.Marshal(v.Mappings)
+// diffMappings returns paths of fields in want that are added or changed from live.
+// Fields that exist only in live are ignored because Elasticsearch cannot remove them.
+// A changed field type returns error because Elasticsearch rejects it.
+func diffMappings(live, want map[string]interface{}) ([]string, error) {
+	diffs, err := diffMappingObject("", withoutTrackingMeta(live), withoutTrackingMeta(want))
 	if err != nil {
-		return false, fmt.Errorf("marshal properties json: %w", err)
+		return nil, err
 	}
+	sort.Strings(diffs)
+	return diffs, nil
+}
+
+func diffMappingObject(path string, live, want map[string]interface{}) ([]string, error) {
+	diffs := make([]string, 0)
+
+	for _, key := range sortedKeys(want) {
+		switch key {
+		case "type":
+			// compared by parent
+			continue
+		case "properties", "fields":
+			wantFields, _ := want[key].(map[string]interface{})
+			liveFields, _ := live[key].(map[string]interface{})
+
+			for _, name := range sortedKeys(wantFields) {
+				p := joinFieldPath(path, name)
+				wantField, _ := wantFields[name].(map[string]interface{})
+				liveField, ok := liveFields[name].(map[string]interface{})
+				if !ok {
+					diffs = append(diffs, p)
+					continue
+				}
 
-	if diff := jsondiff.Diff(b, mappingsJSON); diff != "" {
-		// fmt.Printf("detect mapping diff:\n%s", diff)
-		return false, nil
+				if l, w := fieldType(liveField), fieldType(wantField); l != w {
+					return nil, fmt.Errorf("field %v: changing type from %v to %v is not supported. create new index and reindex", p, l, w)
+				}
 
+				d, err := diffMappingObject(p, liveField, wantField)
+				if err != nil {
+					return nil, err
+				}
+				diffs = append(diffs, d...)
+			}
+		default:
+			l, ok := live[key]
+			if !ok {
+				l, ok = mappingDefaults[key]
+			}
+			if !ok || !reflect.DeepEqual(normalizeMappingValue(l), normalizeMappingValue(want[key])) {
+				diffs = append(diffs, joinFieldPath(path, key))
+			}
+		}
 	}
-	return true, nil
+	return diffs, nil
 }
 
-func (c *esclient) updateIndex(ctx context.Context, index index) error {
-	putMapping := c.client.Indices.PutMapping
-	// putSetting := c.client.Indices.PutSettings
+// normalizeMappingValue converts scalars to string like settingString,
+// because Elasticsearch returns some mapping parameters as string (e.g. "dynamic": "false").
+func normalizeMappingValue(v interface{}) interface{} {
+	switch s := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(s))
+		for k, e := range s {
+			m[k] = normalizeMappingValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, 0, len(s))
+		for _, e := range s {
+			l = append(l, normalizeMappingValue(e))
+		}
+		return l
+	default:
+		return settingString(s)
+	}
+}
 
-	b, err := ioutil.ReadFile(index.Mapping)
-	if err != nil {
-		return fmt.Errorf("open mapping file: %w", err)
+func fieldType(field map[string]interface{}) string {
+	if t, ok := field["type"].(string); ok {
+		return t
 	}
+	return "object"
+}
 
-	config := &indexConfig{}
-	err = json.Unmarshal(b, config)
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// updateIndex applies additive mapping changes and dynamic setting changes to the existing index.
+// Changed static settings are not applied and reported as warning because they need a new index.
+func (c *esclient) updateIndex(ctx context.Context, index index) error {
+	putMapping := c.client.Indices.PutMapping
+	putSettings := c.client.Indices.PutSettings
+
+	config, err := readIndexMapping(index)
 	if err != nil {
-		return fmt.Errorf("unmarshal mapping json: %w", err)
+		return err
 	}
 
 	// mapping --------
-	if config.Mappings == nil {
-		// dose not contain
-		// return fmt.Errorf("get mappings from file: %w", err)
-	} else {
-		j, err := json.Marshal(config.Mappings)
+	if config.Mappings != nil {
+		diffs, err := c.diffMappingsProperties(ctx, index, config.Mappings)
 		if err != nil {
-			return fmt.Errorf("marshal mappings json: %w", err)
+			return fmt.Errorf("update %v mapping: %w", index.Name, err)
 		}
 
-		ok, err := c.equalMappingsProperties(ctx, index, j)
-		if err != nil {
-			return err
-		}
+		if len(diffs) > 0 {
+			mappings := config.Mappings
+			if _, ok := mappings["_meta"]; ok {
+				live, err := c.liveMeta(ctx, index.Name)
+				if err != nil {
+					return fmt.Errorf("update %v mapping: %w", index.Name, err)
+				}
+				mappings = withTrackingMeta(mappings, live)
+			}
+			j, err := json.Marshal(mappings)
+			if err != nil {
+				return fmt.Errorf("marshal mappings json: %w", err)
+			}
 
-		if !ok {
 			res, err := putMapping(
 				bytes.NewReader(j),
 				putMapping.WithIndex(index.Name),
 				putMapping.WithContext(ctx),
 			)
 			if err != nil {
-				return fmt.Errorf("get %v mapping: %w", index, err)
+				return fmt.Errorf("update %v mapping: %w", index.Name, err)
 			}
 			if res.StatusCode != 200 {
 				body, err := ioutil.ReadAll(res.Body)
 				if err != nil {
 					return fmt.Errorf("update %v mapping: %w", index.Name, err)
 				}
-				return fmt.Errorf("update %v mapping: %v", index.Name, string(body))
+				return fmt.Errorf("update %v mapping [fields=%v]: %v", index.Name, strings.Join(diffs, ", "), string(body))
 			}
+			c.logf("[updated] mappings: %v (%v)\n", index.Name, strings.Join(diffs, ", "))
 		}
 	}
 
-	// // setting -------
-	// if config.Settings == nil {
-	// 	// dose not contain
-	// 	// return fmt.Errorf("get settings from file: %w", err)
-	// 	return nil
-	// }
-
-	// j, err := json.Marshal(config.Settings)
-	// if err != nil {
-	// 	return fmt.Errorf("marshal mappings json: %w", err)
-	// }
-
-	// ok, err := c.equalSettings(ctx, index, j)
-	// if err != nil {
-	// 	return err
-	// }
-	// if ok {
-	// 	return nil
-	// }
-
-	// res, err := putSetting(
-	// 	bytes.NewReader(j),
-	// 	putSetting.WithIndex(index.Name),
-	// 	putSetting.WithContext(ctx),
-	// )
-	// if err != nil {
-	// 	return fmt.Errorf("update %v setting: %w", index, err)
-	// }
-	// if res.StatusCode != 200 {
-	// 	body, err := ioutil.ReadAll(res.Body)
-	// 	if err != nil {
-	// 		return fmt.Errorf("update %v setting: %w", index.Name, err)
-	// 	}
-	// 	return fmt.Errorf("update %v setting: %v", index.Name, string(body))
-	// }
+	// setting -------
+	if config.Settings != nil {
+		dynamic, static, err := c.diffSettingsWithLive(ctx, index, config.Settings)
+		if err != nil {
+			return fmt.Errorf("update %v setting: %w", index.Name, err)
+		}
+
+		if len(static) > 0 {
+			c.warnf("[warn] settings: %v (%v are static settings and not applied. create new index and reindex)\n", index.Name, strings.Join(static, ", "))
+		}
+
+		if len(dynamic) > 0 {
+			j, err := json.Marshal(dynamic)
+			if err != nil {
+				return fmt.Errorf("marshal settings json: %w", err)
+			}
+
+			res, err := putSettings(
+				bytes.NewReader(j),
+				putSettings.WithIndex(index.Name),
+				putSettings.WithContext(ctx),
+			)
+			if err != nil {
+				return fmt.Errorf("update %v setting: %w", index.Name, err)
+			}
+			if res.StatusCode != 200 {
+				body, err := ioutil.ReadAll(res.Body)
+				if err != nil {
+					return fmt.Errorf("update %v setting: %w", index.Name, err)
+				}
+				return fmt.Errorf("update %v setting: %v", index.Name, string(body))
+			}
+			c.logf("[updated] settings: %v (%v)\n", index.Name, strings.Join(sortedKeys(dynamic), ", "))
+		}
+	}
 
 	return nil
 }
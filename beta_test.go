@@ -1,7 +1,9 @@
 package eskeeper
 
 import (
+	"bytes"
 	"context"
+	"reflect"
 	"testing"
 )
 
@@ -23,28 +25,50 @@ func TestUpdateIndex(t *testing.T) {
 			},
 			wantErr: false,
 		},
-		// {
-		// 	name: "same",
-		// 	index: index{
-		// 		Name:    "update-test-v2",
-		// 		Mapping: "testdata/test.json",
-		// 	},
-		// 	setup: func(tb testing.TB) {
-		// 		createTmpIndexHelper(tb, "update-test-v2")
-		// 	},
-		// 	wantErr: false,
-		// },
-		// {
-		// 	name: "invalid-update",
-		// 	index: index{
-		// 		Name:    "update-test-v3",
-		// 		Mapping: "testdata/invalidUpdateIndex.json",
-		// 	},
-		// 	setup: func(tb testing.TB) {
-		// 		createTmpIndexHelper(tb, "update-test-v3")
-		// 	},
-		// 	wantErr: true,
-		// },
+		{
+			name: "same",
+			index: index{
+				Name:    "update-test-v2",
+				Mapping: "testdata/test.json",
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "update-test-v2")
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid-update",
+			index: index{
+				Name:    "update-test-v3",
+				Mapping: "testdata/invalidUpdateIndex.json",
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "update-test-v3")
+			},
+			wantErr: true,
+		},
+		{
+			name: "dynamic-settings",
+			index: index{
+				Name:    "update-test-v5",
+				Mapping: "testdata/updateSettings.json",
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "update-test-v5")
+			},
+			wantErr: false,
+		},
+		{
+			name: "change-type",
+			index: index{
+				Name:    "update-test-v4",
+				Mapping: "testdata/changeTypeIndex.json",
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "update-test-v4")
+			},
+			wantErr: true,
+		},
 	}
 
 	es, err := newEsClient([]string{url}, "", "")
@@ -66,3 +90,219 @@ func TestUpdateIndex(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateIndexStaticSettings(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	es.warnOut = &out
+	ctx := context.Background()
+
+	createTmpIndexHelper(t, "update-static-v1")
+	defer deleteIndexHelper(t, []string{"update-static-v1"})
+
+	err = es.updateIndex(ctx, index{
+		Name:     "update-static-v1",
+		Mapping:  "testdata/test.json",
+		Settings: map[string]interface{}{"number_of_shards": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[warn] settings: update-static-v1 (index.number_of_shards are static settings and not applied. create new index and reindex)\n"
+	if got := out.String(); got != want {
+		t.Errorf("\nwant: %q\ngot : %q\n", want, got)
+	}
+}
+
+func TestDiffMappings(t *testing.T) {
+	tests := []struct {
+		name    string
+		live    map[string]interface{}
+		want    map[string]interface{}
+		diffs   []string
+		wantErr bool
+	}{
+		{
+			name: "same",
+			live: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"type": "long"},
+				},
+			},
+			want: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"type": "long", "index": true},
+				},
+			},
+			diffs: []string{},
+		},
+		{
+			name: "normalized-scalars",
+			live: map[string]interface{}{
+				"dynamic": "false",
+				"properties": map[string]interface{}{
+					"title": map[string]interface{}{"type": "keyword", "ignore_above": "256", "norms": "false"},
+				},
+			},
+			want: map[string]interface{}{
+				"dynamic": false,
+				"properties": map[string]interface{}{
+					"title": map[string]interface{}{"type": "keyword", "ignore_above": float64(256), "norms": false},
+				},
+			},
+			diffs: []string{},
+		},
+		{
+			name: "add",
+			live: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"id":   map[string]interface{}{"type": "long"},
+					"tags": map[string]interface{}{"type": "keyword"},
+				},
+			},
+			want: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"type": "long"},
+					"title": map[string]interface{}{
+						"type": "text",
+						"fields": map[string]interface{}{
+							"raw": map[string]interface{}{"type": "keyword"},
+						},
+					},
+				},
+			},
+			diffs: []string{"title"},
+		},
+		{
+			name: "add-multi-field",
+			live: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"title": map[string]interface{}{"type": "text"},
+				},
+			},
+			want: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"title": map[string]interface{}{
+						"type": "text",
+						"fields": map[string]interface{}{
+							"raw": map[string]interface{}{"type": "keyword", "ignore_above": float64(256)},
+						},
+					},
+				},
+			},
+			diffs: []string{"title.raw"},
+		},
+		{
+			name: "change-type",
+			live: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"type": "long"},
+				},
+			},
+			want: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"type": "keyword"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffMappings(tt.live, tt.want)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expect error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.diffs) {
+				t.Errorf("want: %+v, got: %+v\n", tt.diffs, got)
+			}
+		})
+	}
+}
+
+func TestDiffSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		live        map[string]interface{}
+		want        map[string]interface{}
+		wantDynamic map[string]interface{}
+		wantStatic  []string
+	}{
+		{
+			name: "same",
+			live: map[string]interface{}{
+				"index.number_of_shards":   "1",
+				"index.number_of_replicas": "1",
+				"index.refresh_interval":   "1s",
+			},
+			want: map[string]interface{}{
+				"number_of_shards": float64(1),
+				"index": map[string]interface{}{
+					"refresh_interval": "1s",
+				},
+			},
+			wantDynamic: map[string]interface{}{},
+			wantStatic:  []string{},
+		},
+		{
+			name: "dynamic",
+			live: map[string]interface{}{
+				"index.number_of_replicas": "1",
+				"index.max_result_window":  "10000",
+			},
+			want: map[string]interface{}{
+				"number_of_replicas": float64(2),
+				"max_result_window":  float64(10000),
+			},
+			wantDynamic: map[string]interface{}{
+				"index.number_of_replicas": float64(2),
+			},
+			wantStatic: []string{},
+		},
+		{
+			name: "static",
+			live: map[string]interface{}{
+				"index.number_of_shards":                     "1",
+				"index.analysis.analyzer.my_analyzer.filter": []interface{}{"lowercase"},
+			},
+			want: map[string]interface{}{
+				"number_of_shards": float64(2),
+				"analysis": map[string]interface{}{
+					"analyzer": map[string]interface{}{
+						"my_analyzer": map[string]interface{}{
+							"filter": []interface{}{"lowercase", "english_stop"},
+						},
+					},
+				},
+			},
+			wantDynamic: map[string]interface{}{},
+			wantStatic: []string{
+				"index.analysis.analyzer.my_analyzer.filter",
+				"index.number_of_shards",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamic, static := diffSettings(tt.live, tt.want)
+			if !reflect.DeepEqual(dynamic, tt.wantDynamic) {
+				t.Errorf("want: %+v, got: %+v\n", tt.wantDynamic, dynamic)
+			}
+			if !reflect.DeepEqual(static, tt.wantStatic) {
+				t.Errorf("want: %+v, got: %+v\n", tt.wantStatic, static)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package eskeeper
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// blocks is index blocks. fields are the same as index.blocks.* settings.
+// nil means the block is not managed by eskeeper.
+// metadata block is rejected in validation because it blocks reading settings & mappings that eskeeper needs.
+type blocks struct {
+	ReadOnly            *bool `json:"read_only,omitempty"`
+	ReadOnlyAllowDelete *bool `json:"read_only_allow_delete,omitempty"`
+	Write               *bool `json:"write,omitempty"`
+	Metadata            *bool `json:"metadata,omitempty"`
+}
+
+// blockChange is a transition of index block.
+type blockChange struct {
+	Block string
+	From  bool
+	To    bool
+}
+
+func (b blockChange) String() string {
+	return fmt.Sprintf("%s %v -> %v", b.Block, b.From, b.To)
+}
+
+// _block API does not support read_only_allow_delete. it is added by settings.
+var blockAPI = map[string]struct{}{
+	"read_only": struct{}{},
+	"write":     struct{}{},
+}
+
+// declared returns managed blocks in fixed order.
+func (b blocks) declared() []blockChange {
+	declared := make([]blockChange, 0, 3)
+	add := func(name string, v *bool) {
+		if v != nil {
+			declared = append(declared, blockChange{Block: name, To: *v})
+		}
+	}
+	add("read_only", b.ReadOnly)
+	add("read_only_allow_delete", b.ReadOnlyAllowDelete)
+	add("write", b.Write)
+	return declared
+}
+
+// diffBlocks returns transitions of blocks from live flat settings.
+func diffBlocks(live map[string]interface{}, b blocks) []blockChange {
+	changes := make([]blockChange, 0)
+	for _, d := range b.declared() {
+		d.From = settingString(live["index.blocks."+d.Block]) == "true"
+		if d.From != d.To {
+			changes = append(changes, d)
+		}
+	}
+	return changes
+}
+
+func joinBlockChanges(changes []blockChange) string {
+	s := make([]string, 0, len(changes))
+	for _, c := range changes {
+		s = append(s, c.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+func (c *esclient) liveBlockChanges(ctx context.Context, index index) ([]blockChange, error) {
+	live, err := c.settings(ctx, index)
+	if err != nil {
+		return nil, fmt.Errorf("get settings: %w", err)
+	}
+	return diffBlocks(live, *index.Blocks), nil
+}
+
+func (c *esclient) addBlock(ctx context.Context, index, block string) error {
+	if _, ok := blockAPI[block]; !ok {
+		return c.putIndexSettings(ctx, index, map[string]interface{}{"index.blocks." + block: true})
+	}
+
+	addBlock := c.client.Indices.AddBlock
+	res, err := addBlock(
+		[]string{index},
+		block,
+		addBlock.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("add %v block: %w", block, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to add block [index=%v, block=%v, statusCode=%v]", index, block, res.StatusCode)
+		}
+		return fmt.Errorf("failed to add block [index=%v, block=%v, statusCode=%v, res=%v]", index, block, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// unblockIndex removes blocks that are declared false before other changes of the index,
+// because read_only blocks reject updating mappings and settings.
+func (c *esclient) unblockIndex(ctx context.Context, index index) error {
+	if index.Blocks == nil {
+		return nil
+	}
+	ok, err := c.existIndex(ctx, index.Name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	changes, err := c.liveBlockChanges(ctx, index)
+	if err != nil {
+		return fmt.Errorf("update %v blocks: %w", index.Name, err)
+	}
+	settings := make(map[string]interface{}, 0)
+	for _, b := range changes {
+		if !b.To {
+			settings["index.blocks."+b.Block] = false
+		}
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	err = c.putIndexSettings(ctx, index.Name, settings)
+	if err != nil {
+		return fmt.Errorf("update %v blocks: %w", index.Name, err)
+	}
+	return nil
+}
+
+// blockIndex adds blocks that are declared true after other changes of the index.
+func (c *esclient) blockIndex(ctx context.Context, index index) error {
+	if index.Blocks == nil {
+		return nil
+	}
+	changes, err := c.liveBlockChanges(ctx, index)
+	if err != nil {
+		return fmt.Errorf("update %v blocks: %w", index.Name, err)
+	}
+	// read_only_allow_delete rejects _block API. it is added last.
+	for _, api := range []bool{true, false} {
+		for _, b := range changes {
+			if _, ok := blockAPI[b.Block]; !b.To || ok != api {
+				continue
+			}
+			err := c.addBlock(ctx, index.Name, b.Block)
+			if err != nil {
+				return fmt.Errorf("update %v blocks: %w", index.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDiffBlocks(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name   string
+		live   map[string]interface{}
+		blocks blocks
+		want   []blockChange
+	}{
+		{
+			name:   "add",
+			live:   map[string]interface{}{"index.blocks.write": "false"},
+			blocks: blocks{Write: &yes},
+			want:   []blockChange{{Block: "write", From: false, To: true}},
+		},
+		{
+			name:   "remove",
+			live:   map[string]interface{}{"index.blocks.read_only_allow_delete": "true"},
+			blocks: blocks{ReadOnlyAllowDelete: &no},
+			want:   []blockChange{{Block: "read_only_allow_delete", From: true, To: false}},
+		},
+		{
+			name:   "same",
+			live:   map[string]interface{}{"index.blocks.write": "true"},
+			blocks: blocks{Write: &yes, ReadOnly: &no},
+			want:   []blockChange{},
+		},
+		{
+			name:   "unmanaged",
+			live:   map[string]interface{}{"index.blocks.read_only": "true"},
+			blocks: blocks{},
+			want:   []blockChange{},
+		},
+		{
+			name:   "order",
+			live:   map[string]interface{}{},
+			blocks: blocks{Write: &yes, ReadOnly: &yes},
+			want: []blockChange{
+				{Block: "read_only", From: false, To: true},
+				{Block: "write", From: false, To: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffBlocks(tt.live, tt.blocks)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want: %+v, got: %+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateIndexMetadataBlock(t *testing.T) {
+	yes := true
+	err := validateIndex(index{Name: "test-v1", Blocks: &blocks{Metadata: &yes}})
+	if err == nil {
+		t.Error("expect error for metadata block")
+	}
+}
+
+func TestSyncBlocks(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	yes, no := true, false
+	createTmpIndexHelper(t, "blocks-v1")
+	defer deleteIndexHelper(t, []string{"blocks-v1"})
+
+	tests := []struct {
+		name   string
+		blocks blocks
+		want   []Change
+	}{
+		{
+			name:   "freeze",
+			blocks: blocks{Write: &yes, ReadOnlyAllowDelete: &yes},
+			want: []Change{
+				{Resource: "index", Name: "blocks-v1", Action: "update", Detail: "blocks: read_only_allow_delete false -> true, write false -> true"},
+			},
+		},
+		{
+			name:   "unfreeze",
+			blocks: blocks{Write: &no, ReadOnlyAllowDelete: &no},
+			want: []Change{
+				{Resource: "index", Name: "blocks-v1", Action: "update", Detail: "blocks: read_only_allow_delete true -> false, write true -> false"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config{
+				Indices: []index{{Name: "blocks-v1", Blocks: &tt.blocks}},
+			}
+
+			got, err := es.plan(ctx, conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+
+			err = es.syncIndices(ctx, conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err = es.plan(ctx, conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 0 {
+				t.Errorf("want no changes after sync, got: %+v\n", got)
+			}
+		})
+	}
+}
@@ -15,8 +15,9 @@ func (c *esclient) preCheckIndex(ctx context.Context, ix index) error {
 	}
 
 	preIndex := index{
-		Name:    fmt.Sprintf("eskeeper-%s", u2.String()),
-		Mapping: ix.Mapping,
+		Name:     fmt.Sprintf("eskeeper-%s", u2.String()),
+		Mapping:  ix.Mapping,
+		Settings: ix.Settings,
 	}
 
 	err = c.syncIndex(ctx, preIndex)
@@ -49,20 +50,64 @@ func (c *esclient) preCheckAlias(ctx context.Context, alias alias, createIndices
 		if err != nil {
 			return fmt.Errorf("pre-check: check index %v exists for alias %v: %w", index, alias.Name, err)
 		}
-		if !ok {
-			c.logf("[fail] alias: %v\n", alias.Name)
-			return fmt.Errorf("pre-check: index %v for alias %v is not found", index, alias.Name)
+		if ok {
+			continue
+		}
+		// aliases of data streams need Elasticsearch 7.14+ and are not supported yet.
+		ds, err := c.existDataStream(ctx, index)
+		if err != nil {
+			return fmt.Errorf("pre-check: check data stream %v exists for alias %v: %w", index, alias.Name, err)
+		}
+		c.logf("[fail] alias: %v\n", alias.Name)
+		if ds {
+			return fmt.Errorf("pre-check: alias %v refers to data stream %v. aliases of data streams are not supported", alias.Name, index)
 		}
+		return fmt.Errorf("pre-check: index %v for alias %v is not found", index, alias.Name)
 	}
 
 	return nil
 }
 
 func (c *esclient) preCheck(ctx context.Context, conf config) error {
+	err := c.preCheckPolicies(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.preCheckPipelines(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.preCheckScripts(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.preCheckSLMPolicies(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.preCheckDataStreams(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.preCheckTemplates(ctx, conf)
+	if err != nil {
+		return err
+	}
+
 	// use alias pre-check
 	createIndices := make(map[string]struct{}, 0)
 
 	for _, ix := range conf.Indices {
+		if ix.State == "absent" {
+			c.logf("[skip] index %v will be deleted\n", ix.Name)
+			continue
+		}
+
 		ok, err := c.existIndex(ctx, ix.Name)
 		if err != nil {
 			return fmt.Errorf("pre-check: check index %v exists: %w", ix.Name, err)
@@ -74,7 +119,11 @@ func (c *esclient) preCheck(ctx context.Context, conf config) error {
 		}
 
 		createIndices[ix.Name] = struct{}{}
-		err = c.preCheckIndex(ctx, ix)
+		if ix.From != nil {
+			err = c.preCheckFrom(ctx, ix, createIndices)
+		} else {
+			err = c.preCheckIndex(ctx, ix)
+		}
 		if err != nil {
 			c.logf("[fail] index: %v\n", ix.Name)
 			return err
@@ -84,6 +133,21 @@ func (c *esclient) preCheck(ctx context.Context, conf config) error {
 
 	// check target index exists
 	for _, alias := range conf.Aliases {
+		if alias.State == "absent" {
+			c.logf("[skip] alias %v will be deleted\n", alias.Name)
+			continue
+		}
+		if alias.Rollover != nil {
+			ok, err := c.preCheckRollover(ctx, alias)
+			if err != nil {
+				c.logf("[fail] alias: %v\n", alias.Name)
+				return err
+			}
+			if ok {
+				c.logf("[pass] alias: %v\n", alias.Name)
+				continue
+			}
+		}
 		err := c.preCheckAlias(ctx, alias, createIndices)
 		if err != nil {
 			c.logf("[fail] alias: %v\n", alias.Name)
@@ -95,14 +159,52 @@ func (c *esclient) preCheck(ctx context.Context, conf config) error {
 	return nil
 }
 
-// postCheck checks created index & alias by name only.
+// postCheck checks created & deleted resources by name only.
 func (c *esclient) postCheck(ctx context.Context, conf config) error {
+	err := c.postCheckPolicies(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.postCheckPipelines(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.postCheckScripts(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.postCheckSnapshots(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.postCheckDataStreams(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.postCheckTemplates(ctx, conf)
+	if err != nil {
+		return err
+	}
+
 	for _, index := range conf.Indices {
 		ok, err := c.existIndex(ctx, index.Name)
 		if err != nil {
 			c.logf("[fail] index: %v\n", index.Name)
 			return fmt.Errorf("post-check: check created index %v exist: %w", index.Name, err)
 		}
+		if index.State == "absent" {
+			if ok {
+				c.logf("[fail] index: %v\n", index.Name)
+				return fmt.Errorf("post-check: deleted index %v still exists", index.Name)
+			}
+			c.logf("[pass] index: %v\n", index.Name)
+			continue
+		}
 		if !ok {
 			c.logf("[fail] index: %v\n", index.Name)
 			return fmt.Errorf("post-check: created index %v is not found", index.Name)
@@ -116,6 +218,14 @@ func (c *esclient) postCheck(ctx context.Context, conf config) error {
 			c.logf("[fail] alias: %v\n", alias.Name)
 			return fmt.Errorf("post-check: check created alias %v exist: %w", alias.Name, err)
 		}
+		if alias.State == "absent" {
+			if ok {
+				c.logf("[fail] alias: %v\n", alias.Name)
+				return fmt.Errorf("post-check: deleted alias %v still exists", alias.Name)
+			}
+			c.logf("[pass] alias: %v\n", alias.Name)
+			continue
+		}
 		if !ok {
 			c.logf("[fail] alias: %v\n", alias.Name)
 			return fmt.Errorf("post-check: created alias %v is not found", alias.Name)
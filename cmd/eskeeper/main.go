@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/po3rin/eskeeper"
 	"github.com/spf13/cobra"
@@ -12,24 +13,50 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 )
 
+// newSyncEskeeper inits eskeeper with options for sync.
+func newSyncEskeeper() (*eskeeper.Eskeeper, error) {
+	return eskeeper.New(
+		viper.GetStringSlice("es_urls"),
+		eskeeper.UserName(viper.GetString("es_user")),
+		eskeeper.Pass(viper.GetString("es_pass")),
+		eskeeper.Verbose(viper.GetBool("verbose")),
+		eskeeper.SkipPreCheck(viper.GetBool("skip_precheck")),
+		eskeeper.AllowDelete(viper.GetBool("allow_delete")),
+		eskeeper.DeleteMaxDocs(viper.GetInt64("delete_max_docs")),
+		eskeeper.DeleteMaxBytes(viper.GetInt64("delete_max_bytes")),
+		eskeeper.PruneAliasPrefix(viper.GetString("prune_alias_prefix")),
+		eskeeper.SnapshotBeforeSync(viper.GetString("snapshot_before_sync")),
+		eskeeper.BaseDir(viper.GetString("base_dir")),
+		eskeeper.Env(viper.GetString("env")),
+	)
+}
+
+// filenames returns config files & directories of -f option.
+func filenames(cmd *cobra.Command) []string {
+	files, _ := cmd.Flags().GetStringSlice("filename")
+	return files
+}
+
+// requireStdin exits when config is not piped to stdin.
+func requireStdin() {
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stdout, "Currently does not support interactive mode. use -f to read config files")
+		os.Exit(1)
+	}
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "eskeeper",
 	Short: "eskeeper synchronizes index and alias with configuration files while ensuring idempotency.",
 	Run: func(cmd *cobra.Command, args []string) {
-		k, err := eskeeper.New(
-			viper.GetStringSlice("es_urls"),
-			eskeeper.UserName(viper.GetString("es_user")),
-			eskeeper.Pass(viper.GetString("es_pass")),
-			eskeeper.Verbose(viper.GetBool("verbose")),
-			eskeeper.SkipPreCheck(viper.GetBool("skip_precheck")),
-		)
+		k, err := newSyncEskeeper()
 		if err != nil {
 			fmt.Fprintln(os.Stdout, err)
 			os.Exit(1)
 		}
 
 		if terminal.IsTerminal(int(os.Stdin.Fd())) {
-			fmt.Fprintln(os.Stdout, "Currently does not support interactive mode")
+			fmt.Fprintln(os.Stdout, "Currently does not support interactive mode. use apply -f to read config files")
 			os.Exit(1)
 		}
 
@@ -42,6 +69,31 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+var apply = &cobra.Command{
+	Use:   "apply",
+	Short: "Synchronizes config files, directories of *.yaml and multi-document YAML merged into one config",
+	Run: func(cmd *cobra.Command, args []string) {
+		files := filenames(cmd)
+		if len(files) == 0 {
+			fmt.Fprintln(os.Stdout, "apply requires -f option")
+			os.Exit(1)
+		}
+
+		k, err := newSyncEskeeper()
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		err = k.SyncFiles(ctx, files...)
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			os.Exit(1)
+		}
+	},
+}
+
 var validate = &cobra.Command{
 	Use:   "validate",
 	Short: "Validates config",
@@ -49,29 +101,185 @@ var validate = &cobra.Command{
 		k, err := eskeeper.New(
 			[]string{},
 			eskeeper.Verbose(true),
+			eskeeper.BaseDir(viper.GetString("base_dir")),
+			eskeeper.Env(viper.GetString("env")),
 		)
 		if err != nil {
 			fmt.Fprintln(os.Stdout, err)
 			os.Exit(1)
 		}
 
-		if terminal.IsTerminal(int(os.Stdin.Fd())) {
-			fmt.Fprintln(os.Stdout, "Currently does not support interactive mode")
+		ctx := context.Background()
+		if files := filenames(cmd); len(files) > 0 {
+			err = k.ValidateFiles(ctx, files...)
+		} else {
+			requireStdin()
+			err = k.Validate(ctx, os.Stdin)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			os.Exit(1)
+		}
+		fmt.Println("pass")
+	},
+}
+
+var plan = &cobra.Command{
+	Use:   "plan",
+	Short: "Shows changes that sync would apply without touching Elasticsearch",
+	Run: func(cmd *cobra.Command, args []string) {
+		k, err := eskeeper.New(
+			viper.GetStringSlice("es_urls"),
+			eskeeper.UserName(viper.GetString("es_user")),
+			eskeeper.Pass(viper.GetString("es_pass")),
+			eskeeper.PruneAliasPrefix(viper.GetString("prune_alias_prefix")),
+			eskeeper.BaseDir(viper.GetString("base_dir")),
+			eskeeper.Env(viper.GetString("env")),
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
 			os.Exit(1)
 		}
 
 		ctx := context.Background()
-		err = k.Validate(ctx, os.Stdin)
+		var changes []eskeeper.Change
+		if files := filenames(cmd); len(files) > 0 {
+			changes, err = k.PlanFiles(ctx, files...)
+		} else {
+			requireStdin()
+			changes, err = k.Plan(ctx, os.Stdin)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			os.Exit(1)
+		}
+		if len(changes) == 0 {
+			fmt.Println("no changes")
+			return
+		}
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+	},
+}
+
+var drift = &cobra.Command{
+	Use:   "drift",
+	Short: "Detects drift between config and Elasticsearch. exits with 2 when drift exists",
+	Run: func(cmd *cobra.Command, args []string) {
+		k, err := eskeeper.New(
+			viper.GetStringSlice("es_urls"),
+			eskeeper.UserName(viper.GetString("es_user")),
+			eskeeper.Pass(viper.GetString("es_pass")),
+			eskeeper.BaseDir(viper.GetString("base_dir")),
+			eskeeper.Env(viper.GetString("env")),
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		var drifts []eskeeper.Drift
+		if files := filenames(cmd); len(files) > 0 {
+			drifts, err = k.DriftFiles(ctx, files...)
+		} else {
+			requireStdin()
+			drifts, err = k.Drift(ctx, os.Stdin)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			os.Exit(1)
+		}
+		if len(drifts) == 0 {
+			fmt.Println("no drift")
+			return
+		}
+		for _, d := range drifts {
+			fmt.Println(d)
+		}
+		os.Exit(2)
+	},
+}
+
+var tasks = &cobra.Command{
+	Use:   "tasks",
+	Short: "Shows progress of asynchronous reindex tasks",
+	Run: func(cmd *cobra.Command, args []string) {
+		k, err := eskeeper.New(
+			viper.GetStringSlice("es_urls"),
+			eskeeper.UserName(viper.GetString("es_user")),
+			eskeeper.Pass(viper.GetString("es_pass")),
+			eskeeper.BaseDir(viper.GetString("base_dir")),
+			eskeeper.Env(viper.GetString("env")),
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		var ts []eskeeper.Task
+		if files := filenames(cmd); len(files) > 0 {
+			ts, err = k.TasksFiles(ctx, files...)
+		} else {
+			requireStdin()
+			ts, err = k.Tasks(ctx, os.Stdin)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			os.Exit(1)
+		}
+		if len(ts) == 0 {
+			fmt.Println("no tasks")
+			return
+		}
+		for _, t := range ts {
+			fmt.Println(t)
+		}
+	},
+}
+
+var export = &cobra.Command{
+	Use:   "export",
+	Short: "Exports es.yaml and mapping files from indices & aliases in Elasticsearch",
+	Run: func(cmd *cobra.Command, args []string) {
+		k, err := eskeeper.New(
+			viper.GetStringSlice("es_urls"),
+			eskeeper.UserName(viper.GetString("es_user")),
+			eskeeper.Pass(viper.GetString("es_pass")),
+			eskeeper.Verbose(viper.GetBool("verbose")),
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		err = k.Export(ctx, viper.GetString("match"), viper.GetString("out"))
 		if err != nil {
 			fmt.Fprintln(os.Stdout, err)
 			os.Exit(1)
 		}
-		fmt.Println("pass")
 	},
 }
 
 func init() {
+	rootCmd.AddCommand(apply)
 	rootCmd.AddCommand(validate)
+	rootCmd.AddCommand(plan)
+	rootCmd.AddCommand(export)
+	rootCmd.AddCommand(drift)
+	rootCmd.AddCommand(tasks)
+
+	export.Flags().String("match", "*", "Index pattern to export")
+	export.Flags().String("out", ".", "Output directory of es.yaml and mapping files")
+	viper.BindPFlags(export.Flags())
+
+	for _, cmd := range []*cobra.Command{apply, validate, plan, drift, tasks} {
+		cmd.Flags().StringSliceP("filename", "f", []string{}, "Config file or directory of *.yaml files (repeatable)")
+	}
+
 	viper.SetEnvPrefix("eskeeper")
 	viper.AutomaticEnv()
 
@@ -80,6 +288,18 @@ func init() {
 	pflag.StringSliceP("es_urls", "e", []string{"http://localhost:9200"}, "Elasticserch endpoint URLs (comma delimited)")
 	pflag.BoolP("verbose", "v", false, "Make the operation more talkative")
 	pflag.BoolP("skip_precheck", "s", false, "Skip pre-check stage")
+	pflag.Bool("allow_delete", false, "Allow deleting indices with absent state")
+	pflag.Int64("delete_max_docs", 0, "Refuse deleting index that has more docs than this (0 means no limit)")
+	pflag.Int64("delete_max_bytes", 0, "Refuse deleting index larger than this bytes (0 means no limit)")
+	pflag.String("prune_alias_prefix", "", "Remove undeclared aliases whose name starts with this prefix")
+	pflag.String("snapshot_before_sync", "", "Snapshot repository to take snapshot of indices changed by sync before sync stage")
+	pflag.String("base_dir", "", "Directory to resolve relative file paths in config read from stdin (default is working directory)")
+	pflag.String("env", "", "Environment overlay in config to merge into base config (e.g. prod)")
+
+	// accept both --allow-delete and --allow_delete
+	rootCmd.SetGlobalNormalizationFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		return pflag.NormalizedName(strings.ReplaceAll(name, "-", "_"))
+	})
 
 	viper.BindPFlags(pflag.CommandLine)
 }
@@ -6,8 +6,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-
-	"github.com/goccy/go-yaml"
+	"reflect"
 )
 
 var status = map[string]struct{}{
@@ -16,6 +15,30 @@ var status = map[string]struct{}{
 	"":      struct{}{}, // default
 }
 
+var opType = map[string]struct{}{
+	"index":  struct{}{},
+	"create": struct{}{},
+	"":       struct{}{}, // default
+}
+
+var conflicts = map[string]struct{}{
+	"abort":   struct{}{},
+	"proceed": struct{}{},
+	"":        struct{}{}, // default
+}
+
+var state = map[string]struct{}{
+	"present": struct{}{},
+	"absent":  struct{}{},
+	"":        struct{}{}, // default
+}
+
+var resizeOperation = map[string]struct{}{
+	"shrink": struct{}{},
+	"split":  struct{}{},
+	"clone":  struct{}{},
+}
+
 var reindexOn = map[string]struct{}{
 	"always":       struct{}{},
 	"firstCreated": struct{}{},
@@ -23,15 +46,44 @@ var reindexOn = map[string]struct{}{
 }
 
 type config struct {
-	Indices []index `json:"index"`
-	Aliases []alias `json:"alias"` // supports close only
+	Indices            []index          `json:"index,omitempty"`
+	Aliases            []alias          `json:"alias,omitempty"` // supports close only
+	Families           []family         `json:"family,omitempty"`
+	IndexTemplates     []template       `json:"indexTemplate,omitempty"`
+	ComponentTemplates []template       `json:"componentTemplate,omitempty"`
+	ILMPolicies        []ilmPolicy      `json:"ilmPolicy,omitempty"`
+	Pipelines          []ingestPipeline `json:"pipeline,omitempty"`
+	Scripts            []storedScript   `json:"script,omitempty"`
+
+	DataStreams          []dataStream         `json:"dataStream,omitempty"`
+	SnapshotRepositories []snapshotRepository `json:"snapshotRepository,omitempty"`
+	SLMPolicies          []slmPolicy          `json:"slmPolicy,omitempty"`
+
+	Envs map[string]overlay `json:"env,omitempty"` // key is env name
 }
 
 type index struct {
 	Name    string  `json:"name"`
-	Mapping string  `json:"mapping"`
-	Status  string  `json:"status"`
-	Reindex reindex `json:"reindex"`
+	Mapping string  `json:"mapping,omitempty"`
+	Status  string  `json:"status,omitempty"`
+	State   string  `json:"state,omitempty"` // present or absent
+	Reindex reindex `json:"reindex,omitempty"`
+
+	Lifecycle *lifecycle `json:"lifecycle,omitempty"`
+	From      *from      `json:"from,omitempty"`
+	Blocks    *blocks    `json:"blocks,omitempty"`
+
+	// Settings overrides settings in mapping file.
+	Settings map[string]interface{} `json:"settings,omitempty"`
+
+	previous bool // previous version of family. ignored when it does not exist
+}
+
+// from creates index from existing index with shrink, split or clone API instead of mapping.
+// mapping file can only have settings because the index inherits mappings of source index.
+type from struct {
+	Index     string `json:"index"`
+	Operation string `json:"operation"` // shrink, split or clone
 }
 
 type reindex struct {
@@ -39,32 +91,89 @@ type reindex struct {
 	Slices            int    `json:"slices"`
 	WaitForCompletion bool   `json:"waitForCompletion"`
 	On                string `json:"on"`
+
+	// request body options
+	Query        map[string]interface{} `json:"query,omitempty"`
+	SourceFields *sourceFields          `json:"sourceFields,omitempty"`
+	Script       *script                `json:"script,omitempty"`
+	Pipeline     string                 `json:"pipeline,omitempty"`
+	OpType       string                 `json:"opType,omitempty"`
+	MaxDocs      int                    `json:"maxDocs,omitempty"`
+	Conflicts    string                 `json:"conflicts,omitempty"`
+	Size         int                    `json:"size,omitempty"`
+
+	Verify *verify `json:"verify,omitempty"`
+}
+
+// verify checks reindexed data before switching aliases.
+// _count of source and dest are always compared.
+type verify struct {
+	Tolerance  float64 `json:"tolerance,omitempty"`  // allowed ratio of count difference. e.g. 0.01
+	SampleSize int     `json:"sampleSize,omitempty"` // number of sampled document IDs that must exist in dest
+}
+
+// sourceFields is _source filtering of reindex source.
+type sourceFields struct {
+	Includes []string `json:"includes,omitempty"`
+	Excludes []string `json:"excludes,omitempty"`
+}
+
+type script struct {
+	Source string                 `json:"source"`
+	Lang   string                 `json:"lang,omitempty"`
+	Params map[string]interface{} `json:"params,omitempty"`
 }
 
 type alias struct {
-	Name    string   `json:"name"`
-	Indices []string `json:"index"`
+	Name    string                  `json:"name"`
+	Indices []string                `json:"index,omitempty"`
+	State   string                  `json:"state,omitempty"`   // present or absent
+	Options map[string]aliasOptions `json:"options,omitempty"` // key is index name
+
+	Rollover *rollover `json:"rollover,omitempty"`
 }
 
-func yaml2Conf(reader io.Reader) (config, error) {
-	conf := config{}
+// aliasOptions is per-index alias options. fields are the same as the aliases API.
+type aliasOptions struct {
+	Filter        map[string]interface{} `json:"filter,omitempty"`
+	IndexRouting  string                 `json:"index_routing,omitempty"`
+	SearchRouting string                 `json:"search_routing,omitempty"`
+	IsWriteIndex  *bool                  `json:"is_write_index,omitempty"`
+	IsHidden      *bool                  `json:"is_hidden,omitempty"`
+}
 
+// yaml2Conf reads config from reader. Relative file paths in config are resolved against baseDir.
+// Empty baseDir means the working directory. Overlay of env is merged into config.
+func yaml2Conf(reader io.Reader, baseDir, env string) (config, error) {
 	b, err := ioutil.ReadAll(reader)
 	if err != nil {
-		return conf, err
+		return config{}, err
 	}
 
-	if err := yaml.Unmarshal(b, &conf); err != nil {
-		return conf, err
+	l := newConfigLoader()
+	if err := l.load("stdin", baseDir, b); err != nil {
+		return config{}, err
 	}
-
-	return conf, nil
+	return l.config(env)
 }
 
 func validateIndex(index index) error {
 	if index.Name == "" {
 		return errors.New("index name is empty")
 	}
+	_, ok := state[index.State]
+	if !ok {
+		return fmt.Errorf("unsupported state %v", index.State)
+	}
+	if index.State == "absent" {
+		if index.Status != "" || index.Reindex.Source != "" || index.Lifecycle != nil || index.From != nil || index.Blocks != nil {
+			return errors.New("absent state cannot be used with status, reindex, lifecycle, from or blocks")
+		}
+		return nil
+	}
+	if index.Lifecycle != nil && index.Lifecycle.Policy == "" {
+		return errors.New("lifecycle policy is empty")
+	}
 	if index.Mapping != "" {
 		m, err := ioutil.ReadFile(index.Mapping)
 		if err != nil {
@@ -76,11 +185,53 @@ func validateIndex(index index) error {
 			return fmt.Errorf("mapping json is invalid: %w", err)
 		}
 	}
-	_, ok := status[index.Status]
+	_, ok = status[index.Status]
 	if !ok {
 		return fmt.Errorf("unsupported status %v", index.Status)
 	}
 
+	if b := index.Blocks; b != nil {
+		if index.Status == "close" {
+			return errors.New("close status and blocks cannot be used together")
+		}
+		if b.Metadata != nil {
+			return errors.New("metadata block is not supported because it blocks reading index metadata")
+		}
+		if index.Reindex.Source != "" && (boolValue(b.Write) || boolValue(b.ReadOnly) || boolValue(b.ReadOnlyAllowDelete)) {
+			return errors.New("reindex cannot write to index with write, read_only or read_only_allow_delete block")
+		}
+	}
+
+	if index.From != nil {
+		if index.From.Index == "" {
+			return errors.New("from index name is empty")
+		}
+		if index.From.Index == index.Name {
+			return fmt.Errorf("index %v cannot be created from itself", index.Name)
+		}
+		_, ok := resizeOperation[index.From.Operation]
+		if !ok {
+			return fmt.Errorf("unsupported from operation %v. [shrink, split or clone]", index.From.Operation)
+		}
+		if index.Reindex.Source != "" {
+			return errors.New("from and reindex cannot be used together")
+		}
+		if index.Mapping != "" {
+			conf, err := readIndexConfig(index.Mapping)
+			if err != nil {
+				return err
+			}
+			if conf.Mappings != nil {
+				return fmt.Errorf("mapping file of %v cannot have mappings. it inherits mappings of %v", index.Name, index.From.Index)
+			}
+		}
+		if index.From.Operation == "split" {
+			if _, err := targetShards(index, 0); err != nil {
+				return err
+			}
+		}
+	}
+
 	if index.Reindex.Source != "" {
 		if index.Status == "close" {
 			return errors.New("unsupported close status and reindex cannot be used together")
@@ -89,6 +240,31 @@ func validateIndex(index index) error {
 		if !ok {
 			return fmt.Errorf("unsupported reindex hook %v. [always or firstCreated]", index.Reindex.On)
 		}
+		_, ok = opType[index.Reindex.OpType]
+		if !ok {
+			return fmt.Errorf("unsupported reindex opType %v. [index or create]", index.Reindex.OpType)
+		}
+		_, ok = conflicts[index.Reindex.Conflicts]
+		if !ok {
+			return fmt.Errorf("unsupported reindex conflicts %v. [abort or proceed]", index.Reindex.Conflicts)
+		}
+		if index.Reindex.MaxDocs < 0 || index.Reindex.Size < 0 {
+			return errors.New("reindex maxDocs and size must not be negative")
+		}
+		if index.Reindex.Script != nil && index.Reindex.Script.Source == "" {
+			return errors.New("reindex script source is empty")
+		}
+		if v := index.Reindex.Verify; v != nil {
+			if !index.Reindex.WaitForCompletion {
+				return errors.New("reindex verify requires waitForCompletion")
+			}
+			if v.Tolerance < 0 || v.Tolerance > 1 {
+				return fmt.Errorf("reindex verify tolerance %v must be between 0 and 1", v.Tolerance)
+			}
+			if v.SampleSize < 0 {
+				return errors.New("reindex verify sampleSize must not be negative")
+			}
+		}
 	}
 
 	return nil
@@ -98,45 +274,187 @@ func validateAlias(alias alias) error {
 	if alias.Name == "" {
 		return errors.New("alias name is empty")
 	}
+	_, ok := state[alias.State]
+	if !ok {
+		return fmt.Errorf("unsupported state %v", alias.State)
+	}
+	if alias.State == "absent" {
+		return nil
+	}
 
 	if len(alias.Indices) == 0 {
 		return fmt.Errorf("no indices in %v alias", alias.Name)
 	}
 
+	indices := make(map[string]struct{}, len(alias.Indices))
 	for _, index := range alias.Indices {
 		if index == "" {
 			return errors.New("index name is empty")
 		}
+		indices[index] = struct{}{}
+	}
+
+	writeIndices := 0
+	for index, opts := range alias.Options {
+		if _, ok := indices[index]; !ok {
+			return fmt.Errorf("options for index %v not in %v alias", index, alias.Name)
+		}
+		if opts.IsWriteIndex != nil && *opts.IsWriteIndex {
+			writeIndices++
+		}
+	}
+	if writeIndices > 1 {
+		return fmt.Errorf("%v alias has multiple write indices", alias.Name)
+	}
+
+	if alias.Rollover != nil {
+		return validateRollover(alias)
+	}
+	return nil
+}
+
+// resourceNames returns names of resources in list like []ilmPolicy.
+func resourceNames(list interface{}) []string {
+	v := reflect.ValueOf(list)
+	names := make([]string, v.Len())
+	for i := range names {
+		names[i] = v.Index(i).FieldByName("Name").String()
+	}
+	return names
+}
+
+// validateResources rejects duplicated names of kind and validates each resource with validate.
+// validate receives the index in names.
+func (e *Eskeeper) validateResources(kind string, names []string, validate func(i int) error) error {
+	declared := make(map[string]struct{}, len(names))
+	for i, name := range names {
+		if _, ok := declared[name]; ok {
+			e.logf("[fail] %v: %v\n", kind, name)
+			return fmt.Errorf("duplicated %v name %v", kind, name)
+		}
+		declared[name] = struct{}{}
+
+		err := validate(i)
+		if err != nil {
+			e.logf("[fail] %v: %v\n", kind, name)
+			return fmt.Errorf("validate %v: %w", kind, err)
+		}
+		e.logf("[pass] %v: %v\n", kind, name)
 	}
 	return nil
 }
 
 func (e *Eskeeper) validateConfigFormat(c config) error {
-	createIndices := make(map[string]struct{}, 0)
+	err := e.validateResources(componentTemplateKind, resourceNames(c.ComponentTemplates), func(i int) error {
+		return validateTemplate(c.ComponentTemplates[i])
+	})
+	if err != nil {
+		return err
+	}
+	err = e.validateResources(indexTemplateKind, resourceNames(c.IndexTemplates), func(i int) error {
+		return validateTemplate(c.IndexTemplates[i])
+	})
+	if err != nil {
+		return err
+	}
 
-	for _, index := range c.Indices {
-		_, exist := createIndices[index.Name]
-		if exist {
-			e.logf("[fail] index: %v\n", index.Name)
-			return fmt.Errorf("duplicated index name %v", index.Name)
+	err = e.validateResources("ilmPolicy", resourceNames(c.ILMPolicies), func(i int) error {
+		return validateILMPolicy(c.ILMPolicies[i])
+	})
+	if err != nil {
+		return err
+	}
+
+	err = e.validateResources("pipeline", resourceNames(c.Pipelines), func(i int) error {
+		return validatePipeline(c.Pipelines[i])
+	})
+	if err != nil {
+		return err
+	}
+
+	err = e.validateResources("script", resourceNames(c.Scripts), func(i int) error {
+		return validateScript(c.Scripts[i])
+	})
+	if err != nil {
+		return err
+	}
+
+	err = e.validateResources("snapshotRepository", resourceNames(c.SnapshotRepositories), func(i int) error {
+		return validateSnapshotRepository(c.SnapshotRepositories[i])
+	})
+	if err != nil {
+		return err
+	}
+
+	err = e.validateResources("slmPolicy", resourceNames(c.SLMPolicies), func(i int) error {
+		return validateSLMPolicy(c.SLMPolicies[i])
+	})
+	if err != nil {
+		return err
+	}
+
+	err = e.validateResources("family", resourceNames(c.Families), func(i int) error {
+		err := validateFamily(c.Families[i])
+		if err != nil {
+			return err
 		}
+		return validateFamilyNames(c.Families[i], c)
+	})
+	if err != nil {
+		return err
+	}
 
+	policies := make(map[string]struct{}, len(c.ILMPolicies))
+	for _, p := range c.ILMPolicies {
+		policies[p.Name] = struct{}{}
+	}
+	createIndices := make(map[string]struct{}, 0)
+	absentIndices := make(map[string]struct{}, 0)
+	for _, index := range c.Indices {
 		createIndices[index.Name] = struct{}{}
+		if index.State == "absent" {
+			absentIndices[index.Name] = struct{}{}
+		}
+	}
 
+	err = e.validateResources("index", resourceNames(c.Indices), func(i int) error {
+		index := c.Indices[i]
 		err := validateIndex(index)
 		if err != nil {
-			e.logf("[fail] index: %v\n", index.Name)
-			return fmt.Errorf("validate index: %w", err)
+			return err
+		}
+		if index.Lifecycle != nil {
+			if _, ok := policies[index.Lifecycle.Policy]; !ok {
+				return fmt.Errorf("ilm policy %v for index %v is not declared", index.Lifecycle.Policy, index.Name)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		e.logf("[pass] index: %v\n", index.Name)
+	dataStreams := make(map[string]struct{}, len(c.DataStreams))
+	for _, d := range c.DataStreams {
+		dataStreams[d.Name] = struct{}{}
+	}
+	err = e.validateResources("dataStream", resourceNames(c.DataStreams), func(i int) error {
+		d := c.DataStreams[i]
+		if _, ok := createIndices[d.Name]; ok {
+			return fmt.Errorf("data stream name %v is a duplicate of an index name", d.Name)
+		}
+		return validateDataStream(d)
+	})
+	if err != nil {
+		return err
 	}
 
 	for _, alias := range c.Aliases {
 		_, ok := createIndices[alias.Name]
-		if ok {
+		_, ds := dataStreams[alias.Name]
+		if ok || ds {
 			e.logf("[fail] alias: %v\n", alias.Name)
-			return fmt.Errorf("alias name %v is a duplicate of an index name that already exists", alias.Name)
+			return fmt.Errorf("alias name %v is a duplicate of an index or data stream name that already exists", alias.Name)
 		}
 
 		err := validateAlias(alias)
@@ -145,7 +463,32 @@ func (e *Eskeeper) validateConfigFormat(c config) error {
 			return fmt.Errorf("validate alias: %w", err)
 		}
 
+		for _, index := range alias.Indices {
+			if _, ok := absentIndices[index]; ok {
+				e.logf("[fail] alias: %v\n", alias.Name)
+				return fmt.Errorf("alias %v refers to absent index %v", alias.Name, index)
+			}
+			if _, ok := dataStreams[index]; ok {
+				e.logf("[fail] alias: %v\n", alias.Name)
+				return fmt.Errorf("alias %v refers to data stream %v. aliases of data streams are not supported", alias.Name, index)
+			}
+		}
+
 		e.logf("[pass] alias: %v\n", alias.Name)
 	}
 	return nil
 }
+
+// validateDeletion rejects absent indices unless deletion is allowed explicitly.
+func (e *Eskeeper) validateDeletion(c config) error {
+	if e.allowDelete {
+		return nil
+	}
+	for _, index := range c.Indices {
+		if index.State == "absent" {
+			e.logf("[fail] index: %v\n", index.Name)
+			return fmt.Errorf("index %v has absent state. use allow-delete option to delete indices", index.Name)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package eskeeper
 
 import (
+	"errors"
 	"os"
 	"reflect"
 
@@ -8,6 +9,7 @@ import (
 )
 
 func TestYaml2Conf(t *testing.T) {
+	yes, no := true, false
 	tests := []struct {
 		name string
 		yaml string
@@ -72,6 +74,229 @@ func TestYaml2Conf(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "alias-options",
+			yaml: "testdata/es.alias.yaml",
+			want: config{
+				Indices: []index{
+					{
+						Name:    "logs-v1",
+						Mapping: "testdata/test.json",
+					},
+					{
+						Name:    "logs-v2",
+						Mapping: "testdata/test.json",
+					},
+				},
+				Aliases: []alias{
+					{
+						Name:    "tenant-a",
+						Indices: []string{"logs-v1", "logs-v2"},
+						Options: map[string]aliasOptions{
+							"logs-v2": {
+								Filter: map[string]interface{}{
+									"term": map[string]interface{}{"title": "a"},
+								},
+								IndexRouting:  "1",
+								SearchRouting: "1,2",
+								IsWriteIndex:  &yes,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "family",
+			yaml: "testdata/es.family.yaml",
+			want: config{
+				Indices: []index{
+					{
+						Name:     "products-v1",
+						Status:   "close",
+						previous: true,
+					},
+					{
+						Name:     "products-v2",
+						previous: true,
+					},
+					{
+						Name:    "products-v3",
+						Mapping: "testdata/test.json",
+						Reindex: reindex{
+							Source:            "products-v2",
+							Slices:            3,
+							WaitForCompletion: true,
+							On:                "firstCreated",
+						},
+					},
+				},
+				Aliases: []alias{
+					{
+						Name:    "products",
+						Indices: []string{"products-v3"},
+					},
+				},
+				Families: []family{
+					{
+						Name:    "products",
+						Version: 3,
+						Mapping: "testdata/test.json",
+						Keep:    1,
+						Reindex: &reindex{Slices: 3},
+					},
+				},
+			},
+		},
+		{
+			name: "template",
+			yaml: "testdata/es.template.yaml",
+			want: config{
+				ComponentTemplates: []template{
+					{Name: "eskeeper-base", Template: "testdata/componentTemplate.json"},
+				},
+				IndexTemplates: []template{
+					{Name: "eskeeper-logs", Template: "testdata/indexTemplate.json"},
+				},
+			},
+		},
+		{
+			name: "ilm",
+			yaml: "testdata/es.ilm.yaml",
+			want: config{
+				ILMPolicies: []ilmPolicy{
+					{Name: "logs-retention", Policy: "testdata/ilmPolicy.json"},
+				},
+				Indices: []index{
+					{
+						Name:    "logs-000001",
+						Mapping: "testdata/test.json",
+						Lifecycle: &lifecycle{
+							Policy:        "logs-retention",
+							RolloverAlias: "logs",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "pipeline",
+			yaml: "testdata/es.pipeline.yaml",
+			want: config{
+				Pipelines: []ingestPipeline{
+					{
+						Name:     "lowercase-title",
+						Pipeline: "testdata/pipeline.json",
+						Samples: []map[string]interface{}{
+							{"title": "Eskeeper"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "script",
+			yaml: "testdata/es.script.yaml",
+			want: config{
+				Scripts: []storedScript{
+					{
+						Name:   "double-count",
+						Source: "testdata/script.painless",
+						Execute: &scriptExecute{
+							Params: map[string]interface{}{"count": uint64(1)},
+						},
+					},
+					{
+						Name:   "title-search",
+						Lang:   "mustache",
+						Source: "testdata/searchTemplate.mustache",
+					},
+				},
+			},
+		},
+		{
+			name: "snapshot",
+			yaml: "testdata/es.snapshot.yaml",
+			want: config{
+				SnapshotRepositories: []snapshotRepository{
+					{
+						Name: "eskeeper-backup",
+						Type: "fs",
+						Settings: map[string]interface{}{
+							"location": "/tmp/eskeeper-backup",
+							"compress": true,
+						},
+					},
+				},
+				SLMPolicies: []slmPolicy{
+					{Name: "nightly", Policy: "testdata/slmPolicy.json"},
+				},
+			},
+		},
+		{
+			name: "data-stream",
+			yaml: "testdata/es.datastream.yaml",
+			want: config{
+				IndexTemplates: []template{
+					{Name: "eskeeper-stream", Template: "testdata/dataStreamTemplate.json"},
+				},
+				DataStreams: []dataStream{
+					{Name: "eskeeper-stream-app"},
+				},
+			},
+		},
+		{
+			name: "rollover",
+			yaml: "testdata/es.rollover.yaml",
+			want: config{
+				Indices: []index{
+					{Name: "logs-000001", Mapping: "testdata/test.json"},
+				},
+				Aliases: []alias{
+					{
+						Name:    "logs",
+						Indices: []string{"logs-000001"},
+						Rollover: &rollover{
+							Mapping: "testdata/test.json",
+							Conditions: rolloverConditions{
+								MaxAge:  "7d",
+								MaxDocs: 1000,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "from",
+			yaml: "testdata/es.from.yaml",
+			want: config{
+				Indices: []index{
+					{
+						Name:    "logs-shrunk",
+						Mapping: "testdata/resizeTarget.json",
+						From:    &from{Index: "logs-v1", Operation: "shrink"},
+					},
+					{
+						Name: "logs-backup",
+						From: &from{Index: "logs-v1", Operation: "clone"},
+					},
+				},
+			},
+		},
+		{
+			name: "blocks",
+			yaml: "testdata/es.blocks.yaml",
+			want: config{
+				Indices: []index{
+					{
+						Name:    "test-v1",
+						Mapping: "testdata/test.json",
+						Blocks:  &blocks{Write: &yes, ReadOnlyAllowDelete: &no},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -80,7 +305,7 @@ func TestYaml2Conf(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			got, err := yaml2Conf(r)
+			got, err := yaml2Conf(r, "", "")
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -90,3 +315,34 @@ func TestYaml2Conf(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateResources(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		invalid int
+		wantErr bool
+	}{
+		{name: "valid", names: []string{"a", "b"}, invalid: -1},
+		{name: "duplicated", names: []string{"a", "a"}, invalid: -1, wantErr: true},
+		{name: "invalid", names: []string{"a", "b"}, invalid: 1, wantErr: true},
+	}
+
+	e := &Eskeeper{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.validateResources("script", tt.names, func(i int) error {
+				if i == tt.invalid {
+					return errors.New("invalid")
+				}
+				return nil
+			})
+			if tt.wantErr && err == nil {
+				t.Error("expect error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
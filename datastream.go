@@ -0,0 +1,237 @@
+package eskeeper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// dataStream is a data stream. It is created after its matching index template.
+type dataStream struct {
+	Name string `json:"name"`
+}
+
+type dataStreamsResponse struct {
+	DataStreams []liveDataStream `json:"data_streams"`
+}
+
+type liveDataStream struct {
+	Name       string `json:"name"`
+	Generation int    `json:"generation"`
+	Status     string `json:"status"`
+	Template   string `json:"template"`
+	Indices    []struct {
+		IndexName string `json:"index_name"`
+	} `json:"indices"`
+}
+
+// backingIndices returns backing index names from the oldest generation.
+func (d liveDataStream) backingIndices() []string {
+	indices := make([]string, 0, len(d.Indices))
+	for _, i := range d.Indices {
+		indices = append(indices, i.IndexName)
+	}
+	return indices
+}
+
+func (d liveDataStream) String() string {
+	return fmt.Sprintf("generation=%d, backing indices: %s", d.Generation, strings.Join(d.backingIndices(), ", "))
+}
+
+func validateDataStream(d dataStream) error {
+	if d.Name == "" {
+		return errors.New("data stream name is empty")
+	}
+	return nil
+}
+
+// liveDataStream returns data stream in Elasticsearch. nil means not found.
+func (c *esclient) liveDataStream(ctx context.Context, name string) (*liveDataStream, error) {
+	get := c.client.Indices.GetDataStream
+	res, err := get(get.WithName(name), get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get data stream %v: %w", name, err)
+	}
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get data stream %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get data stream [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+
+	got := dataStreamsResponse{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal data stream json: %w", err)
+	}
+	for _, d := range got.DataStreams {
+		if d.Name == name {
+			return &d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *esclient) existDataStream(ctx context.Context, name string) (bool, error) {
+	d, err := c.liveDataStream(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return d != nil, nil
+}
+
+func (c *esclient) createDataStream(ctx context.Context, name string) error {
+	create := c.client.Indices.CreateDataStream
+	res, err := create(name, create.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("create data stream: %w", err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to create data stream [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to create data stream [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// liveIndexTemplates returns all index templates in Elasticsearch.
+func (c *esclient) liveIndexTemplates(ctx context.Context) (map[string]map[string]interface{}, error) {
+	get := c.client.Indices.GetIndexTemplate
+	res, err := get(get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get index templates: %w", err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get index templates: %w", err)
+	}
+	if res.StatusCode == 404 {
+		return map[string]map[string]interface{}{}, nil
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get index templates [statusCode=%v, res=%v]", res.StatusCode, string(body))
+	}
+
+	got := indexTemplatesResponse{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal index templates json: %w", err)
+	}
+	templates := make(map[string]map[string]interface{}, len(got.IndexTemplates))
+	for _, t := range got.IndexTemplates {
+		templates[t.Name] = t.IndexTemplate
+	}
+	return templates, nil
+}
+
+// matchTemplate returns the name of index template with the highest priority whose index_patterns match name.
+func matchTemplate(name string, templates map[string]map[string]interface{}) (string, bool) {
+	matched := ""
+	priority := -1.0
+	for tname, t := range templates {
+		patterns, _ := t["index_patterns"].([]interface{})
+		for _, p := range patterns {
+			ok, err := path.Match(fmt.Sprint(p), name)
+			if err != nil || !ok {
+				continue
+			}
+			pr, _ := t["priority"].(float64)
+			if pr > priority || (pr == priority && tname < matched) {
+				matched = tname
+				priority = pr
+			}
+			break
+		}
+	}
+	return matched, matched != ""
+}
+
+// preCheckDataStreams checks each data stream has a matching index template with data_stream.
+// Declared index templates take precedence over live ones.
+func (c *esclient) preCheckDataStreams(ctx context.Context, conf config) error {
+	if len(conf.DataStreams) == 0 {
+		return nil
+	}
+
+	templates, err := c.liveIndexTemplates(ctx)
+	if err != nil {
+		return fmt.Errorf("pre-check: %w", err)
+	}
+	for _, t := range conf.IndexTemplates {
+		body, err := readJSON(t.Template)
+		if err != nil {
+			return fmt.Errorf("pre-check: %w", err)
+		}
+		// json numbers of live templates are float64 too.
+		templates[t.Name] = body
+	}
+
+	for _, d := range conf.DataStreams {
+		name, ok := matchTemplate(d.Name, templates)
+		if !ok {
+			c.logf("[fail] dataStream: %v\n", d.Name)
+			return fmt.Errorf("pre-check: index template for data stream %v is not found", d.Name)
+		}
+		if _, ok := templates[name]["data_stream"]; !ok {
+			c.logf("[fail] dataStream: %v\n", d.Name)
+			return fmt.Errorf("pre-check: index template %v for data stream %v does not enable data_stream", name, d.Name)
+		}
+		c.logf("[pass] dataStream: %v\n", d.Name)
+	}
+	return nil
+}
+
+func (c *esclient) syncDataStreams(ctx context.Context, conf config) error {
+	for _, d := range conf.DataStreams {
+		ok, err := c.existDataStream(ctx, d.Name)
+		if err != nil {
+			c.logf("[fail] dataStream: %v\n", d.Name)
+			return fmt.Errorf("sync data stream: %w", err)
+		}
+		if !ok {
+			err = c.createDataStream(ctx, d.Name)
+			if err != nil {
+				c.logf("[fail] dataStream: %v\n", d.Name)
+				return fmt.Errorf("sync data stream: %w", err)
+			}
+		}
+		c.logf("[synced] dataStream: %v\n", d.Name)
+	}
+	return nil
+}
+
+// postCheckDataStreams checks data streams exist and reports their backing index generations.
+func (c *esclient) postCheckDataStreams(ctx context.Context, conf config) error {
+	for _, d := range conf.DataStreams {
+		live, err := c.liveDataStream(ctx, d.Name)
+		if err != nil {
+			c.logf("[fail] dataStream: %v\n", d.Name)
+			return fmt.Errorf("post-check: check data stream %v exist: %w", d.Name, err)
+		}
+		if live == nil {
+			c.logf("[fail] dataStream: %v\n", d.Name)
+			return fmt.Errorf("post-check: data stream %v is not found", d.Name)
+		}
+		c.logf("[pass] dataStream: %v (%v)\n", d.Name, live)
+	}
+	return nil
+}
+
+func (c *esclient) planDataStream(ctx context.Context, d dataStream) ([]Change, error) {
+	live, err := c.liveDataStream(ctx, d.Name)
+	if err != nil {
+		return nil, err
+	}
+	if live == nil {
+		return []Change{{Resource: "dataStream", Name: d.Name, Action: "create"}}, nil
+	}
+	return []Change{}, nil
+}
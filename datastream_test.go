@@ -0,0 +1,131 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMatchTemplate(t *testing.T) {
+	templates := map[string]map[string]interface{}{
+		"logs": {
+			"index_patterns": []interface{}{"logs-*"},
+			"priority":       float64(100),
+		},
+		"logs-app": {
+			"index_patterns": []interface{}{"logs-app-*"},
+			"priority":       float64(200),
+		},
+		"metrics": {
+			"index_patterns": []interface{}{"metrics-*"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+		ok     bool
+	}{
+		{name: "match", stream: "logs-web", want: "logs", ok: true},
+		{name: "higher-priority", stream: "logs-app-prod", want: "logs-app", ok: true},
+		{name: "no-priority", stream: "metrics-cpu", want: "metrics", ok: true},
+		{name: "not-found", stream: "traces-app", want: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchTemplate(tt.stream, templates)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("want: %v %v, got: %v %v", tt.want, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestValidateConfigFormatDataStreamAlias(t *testing.T) {
+	e := &Eskeeper{}
+	conf := config{
+		DataStreams: []dataStream{
+			{Name: "eskeeper-stream-app"},
+		},
+		Aliases: []alias{
+			{Name: "eskeeper-stream", Indices: []string{"eskeeper-stream-app"}},
+		},
+	}
+	if err := e.validateConfigFormat(conf); err == nil {
+		t.Error("expect error for alias of data stream")
+	}
+}
+
+func TestSyncDataStreams(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	conf := config{
+		IndexTemplates: []template{
+			{Name: "eskeeper-stream", Template: "testdata/dataStreamTemplate.json"},
+		},
+		DataStreams: []dataStream{
+			{Name: "eskeeper-stream-app"},
+		},
+	}
+
+	// index template is declared but not created yet.
+	if err := es.preCheckDataStreams(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.preCheckDataStreams(ctx, config{DataStreams: []dataStream{{Name: "no-template"}}}); err == nil {
+		t.Error("expect error for data stream without index template")
+	}
+
+	want := []Change{
+		{Resource: "indexTemplate", Name: "eskeeper-stream", Action: "create"},
+		{Resource: "dataStream", Name: "eskeeper-stream-app", Action: "create"},
+	}
+	got, err := es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %+v\ngot : %+v\n", want, got)
+	}
+
+	if err := es.syncTemplates(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.syncDataStreams(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.postCheckDataStreams(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := es.existIndex(ctx, "eskeeper-stream-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("existIndex should resolve data stream")
+	}
+
+	live, err := es.liveDataStream(ctx, "eskeeper-stream-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.Generation != 1 || len(live.backingIndices()) != 1 {
+		t.Errorf("unexpected data stream: %v", live)
+	}
+
+	del := es.client.Indices.DeleteDataStream
+	if _, err := del([]string{"eskeeper-stream-app"}); err != nil {
+		t.Fatal(err)
+	}
+	delTemplate := es.client.Indices.DeleteIndexTemplate
+	if _, err := delTemplate("eskeeper-stream"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,191 @@
+package eskeeper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Drift is a difference between config and Elasticsearch state.
+type Drift struct {
+	Resource string // index, alias or dataStream
+	Name     string
+	Detail   string
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("[drift] %s: %s (%s)", d.Resource, d.Name, d.Detail)
+}
+
+func (c *esclient) driftIndex(ctx context.Context, index index) ([]Drift, error) {
+	drifts := make([]Drift, 0)
+	add := func(format string, a ...interface{}) {
+		drifts = append(drifts, Drift{Resource: "index", Name: index.Name, Detail: fmt.Sprintf(format, a...)})
+	}
+
+	ok, err := c.existIndex(ctx, index.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if index.State == "absent" {
+		if ok {
+			add("exists but state is absent")
+		}
+		return drifts, nil
+	}
+	if !ok {
+		add("not found")
+		return drifts, nil
+	}
+
+	status, err := c.indexStatus(ctx, index.Name)
+	if err != nil {
+		return nil, err
+	}
+	want := index.Status
+	if want == "" {
+		want = "open"
+	}
+	if status != want {
+		add("status is %s, want %s", status, want)
+	}
+
+	if index.Blocks != nil {
+		bs, err := c.liveBlockChanges(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+		if len(bs) > 0 {
+			add("blocks differ: %s", joinBlockChanges(bs))
+		}
+	}
+
+	if !hasIndexConfig(index) {
+		return drifts, nil
+	}
+	conf, err := readIndexMapping(index)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Mappings != nil {
+		live, err := c.liveIndexConfig(ctx, index)
+		if err != nil {
+			return nil, fmt.Errorf("get mappings: %w", err)
+		}
+
+		diffs, err := diffMappings(live.Mappings, conf.Mappings)
+		if err != nil {
+			add("mappings: %v", err)
+		} else if len(diffs) > 0 {
+			add("mappings differ: %s", strings.Join(diffs, ", "))
+		}
+
+		// fields added by hand exist only in live mappings.
+		undeclared, err := diffMappings(conf.Mappings, live.Mappings)
+		if err == nil && len(undeclared) > 0 {
+			add("undeclared mappings: %s", strings.Join(undeclared, ", "))
+		}
+	}
+
+	if conf.Settings != nil {
+		dynamic, static, err := c.diffSettingsWithLive(ctx, index, conf.Settings)
+		if err != nil {
+			return nil, err
+		}
+		keys := append(sortedKeys(dynamic), static...)
+		sort.Strings(keys)
+		if len(keys) > 0 {
+			add("settings differ: %s", strings.Join(keys, ", "))
+		}
+	}
+
+	return drifts, nil
+}
+
+func (c *esclient) driftAlias(ctx context.Context, alias alias) ([]Drift, error) {
+	drifts := make([]Drift, 0)
+	add := func(format string, a ...interface{}) {
+		drifts = append(drifts, Drift{Resource: "alias", Name: alias.Name, Detail: fmt.Sprintf(format, a...)})
+	}
+
+	live, err := c.liveAlias(ctx, alias.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if alias.State == "absent" {
+		if len(live) > 0 {
+			add("exists but state is absent")
+		}
+		return drifts, nil
+	}
+	if len(live) == 0 {
+		add("not found")
+		return drifts, nil
+	}
+	// indices of existing alias are managed by rollover.
+	if alias.Rollover != nil {
+		return drifts, nil
+	}
+
+	current := make([]string, 0, len(live))
+	for index := range live {
+		current = append(current, index)
+	}
+	sort.Strings(current)
+
+	want := make([]string, len(alias.Indices))
+	copy(want, alias.Indices)
+	sort.Strings(want)
+
+	if strings.Join(current, ",") != strings.Join(want, ",") {
+		add("points to %s, want %s", strings.Join(current, ", "), strings.Join(want, ", "))
+		return drifts, nil
+	}
+
+	diffs, err := diffAliasOptions(alias, live)
+	if err != nil {
+		return nil, err
+	}
+	if len(diffs) > 0 {
+		add("options differ: %s", strings.Join(diffs, ", "))
+	}
+	return drifts, nil
+}
+
+// drift compares full state of declared indices & aliases with Elasticsearch.
+// Unlike postCheck, it checks status, mappings, settings and alias membership.
+func (c *esclient) drift(ctx context.Context, conf config) ([]Drift, error) {
+	drifts := make([]Drift, 0)
+
+	for _, d := range conf.DataStreams {
+		ok, err := c.existDataStream(ctx, d.Name)
+		if err != nil {
+			return nil, fmt.Errorf("drift data stream %v: %w", d.Name, err)
+		}
+		if !ok {
+			drifts = append(drifts, Drift{Resource: "dataStream", Name: d.Name, Detail: "not found"})
+		}
+	}
+
+	for _, index := range conf.Indices {
+		ds, err := c.driftIndex(ctx, index)
+		if err != nil {
+			return nil, fmt.Errorf("drift index %v: %w", index.Name, err)
+		}
+		drifts = append(drifts, ds...)
+	}
+
+	for _, alias := range conf.Aliases {
+		ds, err := c.driftAlias(ctx, alias)
+		if err != nil {
+			return nil, fmt.Errorf("drift alias %v: %w", alias.Name, err)
+		}
+		drifts = append(drifts, ds...)
+	}
+
+	return drifts, nil
+}
@@ -0,0 +1,108 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    config
+		setup   func(tb testing.TB)
+		want    []Drift
+		cleanup func(tb testing.TB)
+	}{
+		{
+			name: "no-drift",
+			conf: config{
+				Indices: []index{
+					{
+						Name:    "drift-same-v1",
+						Mapping: "testdata/test.json",
+					},
+				},
+				Aliases: []alias{
+					{
+						Name:    "drift-same-alias",
+						Indices: []string{"drift-same-v1"},
+					},
+				},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "drift-same-v1")
+				createTmpAliasHelper(tb, "drift-same-alias", "drift-same-v1")
+			},
+			want: []Drift{},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"drift-same-v1"})
+			},
+		},
+		{
+			name: "drift",
+			conf: config{
+				Indices: []index{
+					{
+						Name:    "drift-v1",
+						Mapping: "testdata/test.json",
+					},
+					{
+						Name:    "drift-v2",
+						Mapping: "testdata/updateIndex.json",
+					},
+					{
+						Name:    "drift-not-found",
+						Mapping: "testdata/test.json",
+					},
+				},
+				Aliases: []alias{
+					{
+						Name:    "drift-alias",
+						Indices: []string{"drift-v2"},
+					},
+				},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "drift-v1")
+				createTmpIndexHelper(tb, "drift-v2")
+				createTmpAliasHelper(tb, "drift-alias", "drift-v1")
+				closeIndexHelper(tb, "drift-v1")
+			},
+			want: []Drift{
+				{Resource: "index", Name: "drift-v1", Detail: "status is close, want open"},
+				{Resource: "index", Name: "drift-v2", Detail: "mappings differ: append"},
+				{Resource: "index", Name: "drift-v2", Detail: "settings differ: index.analysis.analyzer.my_analyzer.filter, index.analysis.analyzer.my_analyzer.tokenizer, index.analysis.analyzer.my_analyzer.type"},
+				{Resource: "index", Name: "drift-not-found", Detail: "not found"},
+				{Resource: "alias", Name: "drift-alias", Detail: "points to drift-v1, want drift-v2"},
+			},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"drift-v1", "drift-v2"})
+			},
+		},
+	}
+
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			got, err := es.drift(ctx, tt.conf)
+			if err != nil {
+				t.Error(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+			if tt.cleanup != nil {
+				tt.cleanup(t)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package eskeeper
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// overlay patches base config for an environment like dev, staging or prod.
+// Entries with the same name as entries in base config are patched with fields written in the overlay,
+// other entries are added, and entries listed in remove are removed from base config.
+type overlay struct {
+	Config config              `json:",inline"`
+	Remove map[string][]string `json:"remove,omitempty"` // key is kind like index or alias
+
+	// raw is the decoded YAML of the overlay. it tells which fields are written,
+	// so that false, 0 and "" can override base config.
+	raw map[string]interface{}
+}
+
+// UnmarshalYAML decodes overlay keeping the decoded YAML.
+func (o *overlay) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain overlay
+	if err := unmarshal((*plain)(o)); err != nil {
+		return err
+	}
+	return unmarshal(&o.raw)
+}
+
+func mergeOverlay(dst, src overlay) overlay {
+	dst.Config = mergeConfig(dst.Config, src.Config)
+	if len(src.Remove) > 0 && dst.Remove == nil {
+		dst.Remove = make(map[string][]string, len(src.Remove))
+	}
+	for kind, names := range src.Remove {
+		dst.Remove[kind] = append(dst.Remove[kind], names...)
+	}
+
+	// entries in raw are kept in the same order as lists in Config.
+	if len(src.raw) > 0 && dst.raw == nil {
+		dst.raw = make(map[string]interface{}, len(src.raw))
+	}
+	for kind, entries := range src.raw {
+		l, _ := dst.raw[kind].([]interface{})
+		e, _ := entries.([]interface{})
+		dst.raw[kind] = append(l, e...)
+	}
+	return dst
+}
+
+// fieldKey returns the key of the field in yaml.
+func fieldKey(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// kindField returns list of resources in config for kind. kind is the key in yaml like index or alias.
+func kindField(conf reflect.Value, kind string) (reflect.Value, bool) {
+	t := conf.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if fieldKey(t.Field(i)) == kind && t.Field(i).Type.Kind() == reflect.Slice {
+			return conf.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// patchValue overwrites dst with fields of src that are written in raw, the decoded YAML of src.
+// Maps are merged recursively, and lists are replaced.
+func patchValue(dst, src reflect.Value, raw interface{}) {
+	written, isMap := raw.(map[string]interface{})
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			f := src.Type().Field(i)
+			if f.PkgPath != "" {
+				continue // unexported
+			}
+			v, ok := written[fieldKey(f)]
+			if !ok {
+				continue
+			}
+			patchValue(dst.Field(i), src.Field(i), v)
+		}
+	case reflect.Ptr:
+		if src.IsNil() || dst.IsNil() {
+			dst.Set(src)
+			return
+		}
+		patchValue(dst.Elem(), src.Elem(), raw)
+	case reflect.Map:
+		if !isMap || dst.IsNil() {
+			dst.Set(src)
+			return
+		}
+		iter := src.MapRange()
+		for iter.Next() {
+			k, v := iter.Key(), iter.Value()
+			sm, ok1 := v.Interface().(map[string]interface{})
+			dm, ok2 := interfaceValue(dst.MapIndex(k)).(map[string]interface{})
+			if ok1 && ok2 {
+				merged := reflect.ValueOf(copyMap(dm))
+				patchValue(merged, reflect.ValueOf(sm), written[k.String()])
+				dst.SetMapIndex(k, merged)
+				continue
+			}
+			dst.SetMapIndex(k, v)
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+func interfaceValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// applyEnv merges overlay of env into base config. Empty env means base config only.
+func applyEnv(conf config, env string) (config, error) {
+	envs := conf.Envs
+	conf.Envs = nil
+	if env == "" {
+		return conf, nil
+	}
+	o, ok := envs[env]
+	if !ok {
+		return conf, fmt.Errorf("env %v is not declared", env)
+	}
+
+	base := reflect.ValueOf(&conf).Elem()
+
+	// remove ---------
+	for kind, names := range o.Remove {
+		list, ok := kindField(base, kind)
+		if !ok {
+			return conf, fmt.Errorf("unsupported kind %v in remove of env %v", kind, env)
+		}
+		for _, name := range names {
+			kept := reflect.MakeSlice(list.Type(), 0, list.Len())
+			for i := 0; i < list.Len(); i++ {
+				if list.Index(i).FieldByName("Name").String() != name {
+					kept = reflect.Append(kept, list.Index(i))
+				}
+			}
+			if kept.Len() == list.Len() {
+				return conf, fmt.Errorf("%v %v removed in env %v is not declared", kind, name, env)
+			}
+			list.Set(kept)
+		}
+	}
+
+	// patch & add ---------
+	patch := reflect.ValueOf(o.Config)
+	for i := 0; i < patch.NumField(); i++ {
+		entries := patch.Field(i)
+		if entries.Kind() != reflect.Slice {
+			continue
+		}
+		written, _ := o.raw[fieldKey(patch.Type().Field(i))].([]interface{})
+		list := base.Field(i)
+		for j := 0; j < entries.Len(); j++ {
+			entry := entries.Index(j)
+			name := entry.FieldByName("Name").String()
+			var raw interface{}
+			if j < len(written) {
+				raw = written[j]
+			}
+
+			patched := false
+			for k := 0; k < list.Len(); k++ {
+				if list.Index(k).FieldByName("Name").String() == name {
+					patchValue(list.Index(k), entry, raw)
+					patched = true
+					break
+				}
+			}
+			if !patched {
+				list.Set(reflect.Append(list, entry))
+			}
+		}
+	}
+	return conf, nil
+}
@@ -0,0 +1,176 @@
+package eskeeper
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    config
+		wantErr bool
+	}{
+		{
+			name: "base",
+			env:  "",
+			want: config{
+				Indices: []index{
+					{Name: "test-v1", Mapping: "testdata/test.json"},
+					{Name: "debug-v1", Mapping: "testdata/test.json"},
+				},
+				Aliases: []alias{
+					{Name: "test", Indices: []string{"test-v1"}},
+				},
+			},
+		},
+		{
+			name: "prod",
+			env:  "prod",
+			want: config{
+				Indices: []index{
+					{
+						Name:     "test-v1",
+						Mapping:  "testdata/test.json",
+						Settings: map[string]interface{}{"number_of_replicas": uint64(2)},
+					},
+					{Name: "test-v2", Mapping: "testdata/test.json"},
+				},
+				Aliases: []alias{
+					{Name: "test", Indices: []string{"test-v2"}},
+				},
+			},
+		},
+		{
+			name:    "undeclared",
+			env:     "staging",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := os.Open("testdata/es.env.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := yaml2Conf(r, "", tt.env)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyEnvZeroValues(t *testing.T) {
+	in := `
+index:
+  - name: test-v2
+    mapping: testdata/test.json
+    status: close
+    blocks:
+      write: true
+      read_only: true
+    reindex:
+      source: test-v1
+      waitForCompletion: true
+      maxDocs: 10
+
+env:
+  dev:
+    index:
+      - name: test-v2
+        status: ""
+        blocks:
+          write: false
+        reindex:
+          waitForCompletion: false
+          maxDocs: 0
+`
+	yes, no := true, false
+	want := config{
+		Indices: []index{
+			{
+				Name:    "test-v2",
+				Mapping: "testdata/test.json",
+				Blocks:  &blocks{Write: &no, ReadOnly: &yes},
+				Reindex: reindex{Source: "test-v1"},
+			},
+		},
+	}
+
+	got, err := yaml2Conf(strings.NewReader(in), "", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %+v\ngot : %+v\n", want, got)
+	}
+}
+
+func TestApplyEnvRemove(t *testing.T) {
+	base := config{
+		Indices: []index{{Name: "test-v1"}},
+		Envs: map[string]overlay{
+			"dev": {Remove: map[string][]string{"index": {"test-v2"}}},
+			"qa":  {Remove: map[string][]string{"unknown": {"test-v1"}}},
+		},
+	}
+
+	for _, env := range []string{"dev", "qa"} {
+		t.Run(env, func(t *testing.T) {
+			if _, err := applyEnv(base, env); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestReadIndexMapping(t *testing.T) {
+	tests := []struct {
+		name  string
+		index index
+		want  map[string]interface{} // subset of flat settings
+	}{
+		{
+			name:  "override",
+			index: index{Mapping: "testdata/updateSettings.json", Settings: map[string]interface{}{"number_of_replicas": 2}},
+			want: map[string]interface{}{
+				"index.number_of_shards":   float64(1),
+				"index.number_of_replicas": 2,
+				"index.refresh_interval":   "30s",
+			},
+		},
+		{
+			name:  "settings only",
+			index: index{Settings: map[string]interface{}{"index": map[string]interface{}{"number_of_replicas": 2}}},
+			want:  map[string]interface{}{"index.number_of_replicas": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readIndexMapping(tt.index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.want {
+				if !reflect.DeepEqual(got.Settings[k], v) {
+					t.Errorf("%v: want: %+v, got: %+v\n", k, v, got.Settings[k])
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package eskeeper
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
@@ -11,6 +13,14 @@ import (
 type esclient struct {
 	client  *elasticsearch.Client
 	verbose bool
+	warnOut io.Writer // warnings are written regardless of verbose
+
+	// delete guard rails
+	allowDelete    bool
+	deleteMaxDocs  int64
+	deleteMaxBytes int64
+
+	pruneAliasPrefix string
 }
 
 func newEsClient(urls []string, user, pass string) (*esclient, error) {
@@ -35,7 +45,8 @@ func newEsClient(urls []string, user, pass string) (*esclient, error) {
 		return nil, err
 	}
 	return &esclient{
-		client: es,
+		client:  es,
+		warnOut: os.Stderr,
 	}, nil
 }
 
@@ -50,3 +61,7 @@ func (e *esclient) logf(format string, a ...interface{}) {
 		fmt.Printf(format, a...)
 	}
 }
+
+func (e *esclient) warnf(format string, a ...interface{}) {
+	fmt.Fprintf(e.warnOut, format, a...)
+}
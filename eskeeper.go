@@ -15,6 +15,17 @@ type Eskeeper struct {
 	pass         string
 	verbose      bool
 	skipPreCheck bool
+
+	allowDelete    bool
+	deleteMaxDocs  int64
+	deleteMaxBytes int64
+
+	pruneAliasPrefix string
+
+	snapshotRepository string
+
+	baseDir string
+	env     string
 }
 
 // NewOption is optional func for eskeeper.New
@@ -48,6 +59,56 @@ func SkipPreCheck(v bool) NewOption {
 	}
 }
 
+// AllowDelete is optional func for deleting indices with absent state.
+func AllowDelete(v bool) NewOption {
+	return func(e *Eskeeper) {
+		e.allowDelete = v
+	}
+}
+
+// DeleteMaxDocs is optional func to refuse deleting index that has more docs than n. 0 means no limit.
+func DeleteMaxDocs(n int64) NewOption {
+	return func(e *Eskeeper) {
+		e.deleteMaxDocs = n
+	}
+}
+
+// DeleteMaxBytes is optional func to refuse deleting index larger than n bytes. 0 means no limit.
+func DeleteMaxBytes(n int64) NewOption {
+	return func(e *Eskeeper) {
+		e.deleteMaxBytes = n
+	}
+}
+
+// PruneAliasPrefix is optional func for removing undeclared aliases whose name starts with prefix.
+func PruneAliasPrefix(prefix string) NewOption {
+	return func(e *Eskeeper) {
+		e.pruneAliasPrefix = prefix
+	}
+}
+
+// SnapshotBeforeSync is optional func for taking snapshot of indices changed by sync into repository before sync stage.
+func SnapshotBeforeSync(repository string) NewOption {
+	return func(e *Eskeeper) {
+		e.snapshotRepository = repository
+	}
+}
+
+// BaseDir is optional func for resolving relative file paths in config read from io.Reader. Default is the working directory.
+// Config loaded from files always resolves paths relative to the file.
+func BaseDir(dir string) NewOption {
+	return func(e *Eskeeper) {
+		e.baseDir = dir
+	}
+}
+
+// Env is optional func for merging overlay of env declared in config into base config.
+func Env(name string) NewOption {
+	return func(e *Eskeeper) {
+		e.env = name
+	}
+}
+
 // New inits Eskeeper.
 func New(urls []string, opts ...NewOption) (*Eskeeper, error) {
 	eskeeper := &Eskeeper{}
@@ -62,6 +123,10 @@ func New(urls []string, opts ...NewOption) (*Eskeeper, error) {
 	}
 
 	es.verbose = eskeeper.verbose
+	es.allowDelete = eskeeper.allowDelete
+	es.deleteMaxDocs = eskeeper.deleteMaxDocs
+	es.deleteMaxBytes = eskeeper.deleteMaxBytes
+	es.pruneAliasPrefix = eskeeper.pruneAliasPrefix
 	eskeeper.client = es
 
 	return eskeeper, nil
@@ -70,13 +135,35 @@ func New(urls []string, opts ...NewOption) (*Eskeeper, error) {
 // Sync synchronizes config & Elasticsearch State.
 func (e *Eskeeper) Sync(ctx context.Context, reader io.Reader) error {
 	e.log("loading config ...")
-	conf, err := yaml2Conf(reader)
+	conf, err := yaml2Conf(reader, e.baseDir, e.env)
 	if err != nil {
 		return err
 	}
+	return e.sync(ctx, conf)
+}
 
+// SyncFiles synchronizes config loaded from files & Elasticsearch State.
+// paths can be yaml files (multi-document supported) or directories of *.yaml files, and they are merged into one config.
+func (e *Eskeeper) SyncFiles(ctx context.Context, paths ...string) error {
+	e.log("loading config ...")
+	conf, err := loadConfig(paths, e.env)
+	if err != nil {
+		return err
+	}
+	return e.sync(ctx, conf)
+}
+
+func (e *Eskeeper) sync(ctx context.Context, conf config) error {
 	e.log("\n=== validation stage ===")
-	err = e.validateConfigFormat(conf)
+	err := e.validateConfigFormat(conf)
+	if err != nil {
+		return err
+	}
+	err = e.validateDeletion(conf)
+	if err != nil {
+		return err
+	}
+	conf, err = e.client.withoutMissingPrevious(ctx, conf)
 	if err != nil {
 		return err
 	}
@@ -89,7 +176,51 @@ func (e *Eskeeper) Sync(ctx context.Context, reader io.Reader) error {
 		}
 	}
 
+	if e.snapshotRepository != "" {
+		e.log("\n=== snapshot stage ===")
+		name, err := e.client.snapshotBeforeSync(ctx, e.snapshotRepository, conf)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			e.log("no indices to snapshot")
+		} else {
+			// printed regardless of verbose because it is needed to restore.
+			fmt.Printf("[snapshot] %v/%v\n", e.snapshotRepository, name)
+		}
+	}
+
 	e.log("\n=== sync stage ===")
+	err = e.client.syncSnapshots(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = e.client.syncPolicies(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = e.client.syncPipelines(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = e.client.syncScripts(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = e.client.syncTemplates(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	err = e.client.syncDataStreams(ctx, conf)
+	if err != nil {
+		return err
+	}
+
 	err = e.client.syncIndices(ctx, conf)
 	if err != nil {
 		return err
@@ -100,11 +231,21 @@ func (e *Eskeeper) Sync(ctx context.Context, reader io.Reader) error {
 		return err
 	}
 
+	err = e.client.syncRollovers(ctx, conf)
+	if err != nil {
+		return err
+	}
+
 	err = e.client.syncCloseStatus(ctx, conf)
 	if err != nil {
 		return err
 	}
 
+	err = e.client.syncAbsentIndices(ctx, conf)
+	if err != nil {
+		return err
+	}
+
 	e.log("\n=== post-check stage ===")
 	err = e.client.postCheck(ctx, conf)
 	if err != nil {
@@ -117,14 +258,119 @@ func (e *Eskeeper) Sync(ctx context.Context, reader io.Reader) error {
 
 // Validate validates cofig.
 func (e *Eskeeper) Validate(ctx context.Context, reader io.Reader) error {
-	conf, err := yaml2Conf(reader)
+	conf, err := yaml2Conf(reader, e.baseDir, e.env)
 	if err != nil {
 		return err
 	}
-	err = e.validateConfigFormat(conf)
+	return e.validateConfigFormat(conf)
+}
+
+// ValidateFiles validates config loaded from files like SyncFiles.
+func (e *Eskeeper) ValidateFiles(ctx context.Context, paths ...string) error {
+	conf, err := loadConfig(paths, e.env)
 	if err != nil {
 		return err
 	}
+	return e.validateConfigFormat(conf)
+}
+
+// Plan lists changes that Sync would apply without touching Elasticsearch.
+func (e *Eskeeper) Plan(ctx context.Context, reader io.Reader) ([]Change, error) {
+	conf, err := yaml2Conf(reader, e.baseDir, e.env)
+	if err != nil {
+		return nil, err
+	}
+	return e.plan(ctx, conf)
+}
+
+// PlanFiles lists changes that SyncFiles would apply without touching Elasticsearch.
+func (e *Eskeeper) PlanFiles(ctx context.Context, paths ...string) ([]Change, error) {
+	conf, err := loadConfig(paths, e.env)
+	if err != nil {
+		return nil, err
+	}
+	return e.plan(ctx, conf)
+}
+
+func (e *Eskeeper) plan(ctx context.Context, conf config) ([]Change, error) {
+	err := e.validateConfigFormat(conf)
+	if err != nil {
+		return nil, err
+	}
+	conf, err = e.client.withoutMissingPrevious(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	return e.client.plan(ctx, conf)
+}
+
+// Drift compares declared indices & aliases with Elasticsearch state and returns the differences.
+func (e *Eskeeper) Drift(ctx context.Context, reader io.Reader) ([]Drift, error) {
+	conf, err := yaml2Conf(reader, e.baseDir, e.env)
+	if err != nil {
+		return nil, err
+	}
+	return e.drift(ctx, conf)
+}
+
+// DriftFiles compares indices & aliases declared in files with Elasticsearch state.
+func (e *Eskeeper) DriftFiles(ctx context.Context, paths ...string) ([]Drift, error) {
+	conf, err := loadConfig(paths, e.env)
+	if err != nil {
+		return nil, err
+	}
+	return e.drift(ctx, conf)
+}
+
+func (e *Eskeeper) drift(ctx context.Context, conf config) ([]Drift, error) {
+	err := e.validateConfigFormat(conf)
+	if err != nil {
+		return nil, err
+	}
+	conf, err = e.client.withoutMissingPrevious(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	return e.client.drift(ctx, conf)
+}
+
+// Tasks returns asynchronous reindex tasks tracked in declared indices.
+func (e *Eskeeper) Tasks(ctx context.Context, reader io.Reader) ([]Task, error) {
+	conf, err := yaml2Conf(reader, e.baseDir, e.env)
+	if err != nil {
+		return nil, err
+	}
+	return e.tasks(ctx, conf)
+}
+
+// TasksFiles returns asynchronous reindex tasks tracked in indices declared in files.
+func (e *Eskeeper) TasksFiles(ctx context.Context, paths ...string) ([]Task, error) {
+	conf, err := loadConfig(paths, e.env)
+	if err != nil {
+		return nil, err
+	}
+	return e.tasks(ctx, conf)
+}
+
+func (e *Eskeeper) tasks(ctx context.Context, conf config) ([]Task, error) {
+	err := e.validateConfigFormat(conf)
+	if err != nil {
+		return nil, err
+	}
+	return e.client.tasks(ctx, conf)
+}
+
+// Export writes es.yaml and mapping files of indices matching pattern and their aliases to dir.
+func (e *Eskeeper) Export(ctx context.Context, pattern, dir string) error {
+	conf, configs, err := e.client.export(ctx, pattern, dir)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	err = writeExport(dir, conf, configs)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	e.logf("exported %v indices and %v aliases to %v\n", len(conf.Indices), len(conf.Aliases), dir)
 	return nil
 }
 
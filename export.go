@@ -0,0 +1,214 @@
+package eskeeper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// serverSettings are generated by Elasticsearch and cannot be set by create index API.
+var serverSettings = map[string]struct{}{
+	"index.uuid":                  struct{}{},
+	"index.creation_date":         struct{}{},
+	"index.provided_name":         struct{}{},
+	"index.history_uuid":          struct{}{},
+	"index.verified_before_close": struct{}{},
+}
+
+var serverSettingPrefixes = []string{
+	"index.version.",
+	"index.resize.",
+	"index.routing.allocation.initial_recovery.",
+}
+
+func isServerSetting(key string) bool {
+	if _, ok := serverSettings[key]; ok {
+		return true
+	}
+	for _, p := range serverSettingPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// indicesConfig returns settings & mappings of open and closed indices matching pattern.
+func (c *esclient) indicesConfig(ctx context.Context, pattern string) (indexConfigWithName, error) {
+	get := c.client.Indices.Get
+	res, err := get(
+		[]string{pattern},
+		get.WithContext(ctx),
+		get.WithFlatSettings(true),
+		get.WithExpandWildcards("open,closed"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get %v: %w", pattern, err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get %v: %w", pattern, err)
+	}
+	if res.StatusCode == 404 {
+		return indexConfigWithName{}, nil
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("get %v: %v", pattern, string(body))
+	}
+
+	got := make(indexConfigWithName, 0)
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal index json: %w", err)
+	}
+	return got, nil
+}
+
+// exportSettings strips server generated settings and converts flat settings to nested settings.
+func exportSettings(flat map[string]interface{}) map[string]interface{} {
+	nested := make(map[string]interface{}, 0)
+	for key, v := range flat {
+		if isServerSetting(key) {
+			continue
+		}
+
+		m := nested
+		keys := strings.Split(key, ".")
+		for _, k := range keys[:len(keys)-1] {
+			child, ok := m[k].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{}, 0)
+				m[k] = child
+			}
+			m = child
+		}
+		m[keys[len(keys)-1]] = v
+	}
+	return nested
+}
+
+// export builds config & index configs from indices matching pattern and their aliases.
+// Mapping file of each index is named <dir>/<index>.json.
+func (c *esclient) export(ctx context.Context, pattern, dir string) (config, map[string]indexConfig, error) {
+	conf := config{}
+
+	live, err := c.indicesConfig(ctx, pattern)
+	if err != nil {
+		return conf, nil, err
+	}
+
+	rows, err := c.catIndices(ctx, pattern, "index", "status")
+	if err != nil {
+		return conf, nil, err
+	}
+	statuses := make(map[string]string, len(rows))
+	for _, row := range rows {
+		statuses[row["index"]] = row["status"]
+	}
+
+	names := make([]string, 0, len(live))
+	for name := range live {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	configs := make(map[string]indexConfig, len(names))
+	aliasNames := make(map[string]struct{}, 0)
+
+	for _, name := range names {
+		ix := index{
+			Name:    name,
+			Mapping: filepath.Join(dir, name+".json"),
+		}
+		if statuses[name] == "close" {
+			ix.Status = "close"
+		}
+		conf.Indices = append(conf.Indices, ix)
+
+		configs[name] = indexConfig{
+			Settings: exportSettings(live[name].Settings),
+			Mappings: live[name].Mappings,
+		}
+
+		aliases, err := c.indexAliases(ctx, name)
+		if err != nil {
+			return conf, nil, err
+		}
+		for _, a := range aliases {
+			aliasNames[a] = struct{}{}
+		}
+	}
+
+	aliases := make([]string, 0, len(aliasNames))
+	for name := range aliasNames {
+		aliases = append(aliases, name)
+	}
+	sort.Strings(aliases)
+
+	for _, name := range aliases {
+		// alias may point to indices not matching pattern.
+		liveAlias, err := c.liveAlias(ctx, name)
+		if err != nil {
+			return conf, nil, err
+		}
+
+		a := alias{Name: name}
+		for index, opts := range liveAlias {
+			a.Indices = append(a.Indices, index)
+			if !reflect.DeepEqual(opts, aliasOptions{}) {
+				if a.Options == nil {
+					a.Options = make(map[string]aliasOptions, 0)
+				}
+				a.Options[index] = opts
+			}
+		}
+		sort.Strings(a.Indices)
+		conf.Aliases = append(conf.Aliases, a)
+	}
+
+	return conf, configs, nil
+}
+
+func writeExport(dir string, conf config, configs map[string]indexConfig) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create export dir: %w", err)
+	}
+
+	for _, ix := range conf.Indices {
+		b, err := json.MarshalIndent(configs[ix.Name], "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal %v mapping json: %w", ix.Name, err)
+		}
+		if err := ioutil.WriteFile(ix.Mapping, append(b, '\n'), 0644); err != nil {
+			return fmt.Errorf("write %v mapping file: %w", ix.Name, err)
+		}
+	}
+
+	// mapping paths in es.yaml are relative to es.yaml.
+	exported := conf
+	exported.Indices = make([]index, 0, len(conf.Indices))
+	for _, ix := range conf.Indices {
+		rel, err := filepath.Rel(dir, ix.Mapping)
+		if err != nil {
+			return fmt.Errorf("relative path of %v mapping file: %w", ix.Name, err)
+		}
+		ix.Mapping = rel
+		exported.Indices = append(exported.Indices, ix)
+	}
+
+	b, err := yaml.Marshal(exported)
+	if err != nil {
+		return fmt.Errorf("marshal config yaml: %w", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "es.yaml"), b, 0644); err != nil {
+		return fmt.Errorf("write config yaml: %w", err)
+	}
+	return nil
+}
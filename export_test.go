@@ -0,0 +1,118 @@
+package eskeeper
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExportSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		flat map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "simple",
+			flat: map[string]interface{}{
+				"index.number_of_shards":                     "1",
+				"index.uuid":                                 "xxxx",
+				"index.creation_date":                        "1614556800000",
+				"index.version.created":                      "7110199",
+				"index.provided_name":                        "test-v1",
+				"index.analysis.analyzer.my_analyzer.filter": []interface{}{"lowercase"},
+			},
+			want: map[string]interface{}{
+				"index": map[string]interface{}{
+					"number_of_shards": "1",
+					"analysis": map[string]interface{}{
+						"analyzer": map[string]interface{}{
+							"my_analyzer": map[string]interface{}{
+								"filter": []interface{}{"lowercase"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exportSettings(tt.flat)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		setup   func(tb testing.TB)
+		cleanup func(tb testing.TB)
+	}{
+		{
+			name:    "no-op",
+			pattern: "export-*",
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "export-v1")
+				createTmpIndexHelper(tb, "export-v2")
+				createTmpAliasHelper(tb, "export-alias", "export-v1")
+				closeIndexHelper(tb, "export-v2")
+			},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"export-v1", "export-v2"})
+			},
+		},
+	}
+
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+
+			dir := t.TempDir()
+			conf, configs, err := es.export(ctx, tt.pattern, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = writeExport(dir, conf, configs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// syncing exported config should be no-op.
+			loaded, err := loadConfig([]string{filepath.Join(dir, "es.yaml")}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, ix := range loaded.Indices {
+				if ix.Mapping != conf.Indices[i].Mapping {
+					t.Errorf("want mapping: %v, got: %v\n", conf.Indices[i].Mapping, ix.Mapping)
+				}
+			}
+			changes, err := es.plan(ctx, loaded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) != 0 {
+				t.Errorf("want no changes, got: %+v\n", changes)
+			}
+
+			if tt.cleanup != nil {
+				tt.cleanup(t)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package eskeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// family is blue/green versioned indices. Version N is expanded into <name>-v<N> index
+// reindexed from the previous version and <name> alias pointing to it.
+type family struct {
+	Name    string   `json:"name"`
+	Version int      `json:"version"`
+	Mapping string   `json:"mapping,omitempty"`
+	Keep    int      `json:"keep,omitempty"`    // number of previous versions kept open. older versions are closed
+	Reindex *reindex `json:"reindex,omitempty"` // reindex options. source is the previous version
+}
+
+func familyIndexName(name string, version int) string {
+	return fmt.Sprintf("%s-v%d", name, version)
+}
+
+func validateFamily(f family) error {
+	if f.Name == "" {
+		return errors.New("family name is empty")
+	}
+	if f.Version < 1 {
+		return fmt.Errorf("family %v version must be positive", f.Name)
+	}
+	if f.Keep < 0 {
+		return fmt.Errorf("family %v keep must not be negative", f.Name)
+	}
+	if f.Keep >= f.Version {
+		return fmt.Errorf("family %v keep must be less than version", f.Name)
+	}
+	if f.Reindex != nil && f.Reindex.Source != "" {
+		return fmt.Errorf("family %v reindex source is the previous version and cannot be set", f.Name)
+	}
+	return nil
+}
+
+// expandFamily expands family into indices & alias.
+// Previous versions are marked so that missing ones are ignored.
+func expandFamily(f family) ([]index, alias) {
+	indices := make([]index, 0, f.Version)
+	for v := 1; v < f.Version; v++ {
+		ix := index{
+			Name:     familyIndexName(f.Name, v),
+			previous: true,
+		}
+		if v < f.Version-f.Keep {
+			ix.Status = "close"
+		}
+		indices = append(indices, ix)
+	}
+
+	current := index{
+		Name:    familyIndexName(f.Name, f.Version),
+		Mapping: f.Mapping,
+	}
+	if f.Version > 1 {
+		r := reindex{}
+		if f.Reindex != nil {
+			r = *f.Reindex
+		}
+		r.Source = familyIndexName(f.Name, f.Version-1)
+		// alias is switched right after reindex.
+		r.WaitForCompletion = true
+		if r.On == "" {
+			r.On = "firstCreated"
+		}
+		current.Reindex = r
+	}
+	indices = append(indices, current)
+
+	return indices, alias{Name: f.Name, Indices: []string{current.Name}}
+}
+
+// validateFamilyNames rejects family whose alias or indices clash with declared indices & aliases.
+// conf is expanded config, so it has alias & indices expanded from the family once.
+func validateFamilyNames(f family, conf config) error {
+	indices := make(map[string]int, len(conf.Indices))
+	for _, index := range conf.Indices {
+		indices[index.Name]++
+	}
+	aliases := 0
+	for _, a := range conf.Aliases {
+		if a.Name == f.Name {
+			aliases++
+		}
+	}
+
+	if aliases > 1 {
+		return fmt.Errorf("family %v is a duplicate of an alias name", f.Name)
+	}
+	if indices[f.Name] > 0 {
+		return fmt.Errorf("family %v is a duplicate of an index name", f.Name)
+	}
+	for v := 1; v <= f.Version; v++ {
+		name := familyIndexName(f.Name, v)
+		if indices[name] > 1 {
+			return fmt.Errorf("index %v of family %v is a duplicate of an index name", name, f.Name)
+		}
+	}
+	return nil
+}
+
+// expandFamilies appends indices & aliases expanded from families to config.
+// Families are validated in validation stage with expanded config.
+func expandFamilies(conf config) config {
+	for _, f := range conf.Families {
+		indices, alias := expandFamily(f)
+		conf.Indices = append(conf.Indices, indices...)
+		conf.Aliases = append(conf.Aliases, alias)
+	}
+	return conf
+}
+
+// withoutMissingPrevious drops previous versions of families that do not exist.
+// Index reindexed from a dropped version is created without reindex.
+func (c *esclient) withoutMissingPrevious(ctx context.Context, conf config) (config, error) {
+	indices := make([]index, 0, len(conf.Indices))
+	dropped := make(map[string]struct{}, 0)
+	for _, index := range conf.Indices {
+		if index.previous {
+			ok, err := c.existIndex(ctx, index.Name)
+			if err != nil {
+				return conf, fmt.Errorf("check previous version %v exists: %w", index.Name, err)
+			}
+			if !ok {
+				c.logf("[skip] index %v is not found\n", index.Name)
+				dropped[index.Name] = struct{}{}
+				continue
+			}
+		}
+		indices = append(indices, index)
+	}
+	for i, index := range indices {
+		if _, ok := dropped[index.Reindex.Source]; ok {
+			c.logf("[skip] reindex (%v -> %v): %v is not found\n", index.Reindex.Source, index.Name, index.Reindex.Source)
+			indices[i].Reindex = reindex{}
+		}
+	}
+	conf.Indices = indices
+	return conf, nil
+}
@@ -0,0 +1,182 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestExpandFamily(t *testing.T) {
+	tests := []struct {
+		name        string
+		family      family
+		wantIndices []index
+		wantAlias   alias
+	}{
+		{
+			name:   "first-version",
+			family: family{Name: "products", Version: 1, Mapping: "testdata/test.json"},
+			wantIndices: []index{
+				{Name: "products-v1", Mapping: "testdata/test.json"},
+			},
+			wantAlias: alias{Name: "products", Indices: []string{"products-v1"}},
+		},
+		{
+			name: "close-previous",
+			family: family{
+				Name:    "products",
+				Version: 3,
+				Reindex: &reindex{On: "always", Slices: 2},
+			},
+			wantIndices: []index{
+				{Name: "products-v1", Status: "close", previous: true},
+				{Name: "products-v2", Status: "close", previous: true},
+				{
+					Name: "products-v3",
+					Reindex: reindex{
+						Source:            "products-v2",
+						Slices:            2,
+						WaitForCompletion: true,
+						On:                "always",
+					},
+				},
+			},
+			wantAlias: alias{Name: "products", Indices: []string{"products-v3"}},
+		},
+		{
+			name:   "keep-previous",
+			family: family{Name: "products", Version: 3, Keep: 2},
+			wantIndices: []index{
+				{Name: "products-v1", previous: true},
+				{Name: "products-v2", previous: true},
+				{
+					Name: "products-v3",
+					Reindex: reindex{
+						Source:            "products-v2",
+						WaitForCompletion: true,
+						On:                "firstCreated",
+					},
+				},
+			},
+			wantAlias: alias{Name: "products", Indices: []string{"products-v3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indices, alias := expandFamily(tt.family)
+			if !reflect.DeepEqual(indices, tt.wantIndices) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.wantIndices, indices)
+			}
+			if !reflect.DeepEqual(alias, tt.wantAlias) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.wantAlias, alias)
+			}
+		})
+	}
+}
+
+func TestValidateFamilies(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    config
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			conf: config{Families: []family{{Name: "products", Version: 2}}},
+		},
+		{
+			name:    "invalid-version",
+			conf:    config{Families: []family{{Name: "products"}}},
+			wantErr: true,
+		},
+		{
+			name:    "keep",
+			conf:    config{Families: []family{{Name: "products", Version: 2, Keep: 2}}},
+			wantErr: true,
+		},
+		{
+			name:    "reindex-source",
+			conf:    config{Families: []family{{Name: "products", Version: 2, Reindex: &reindex{Source: "other"}}}},
+			wantErr: true,
+		},
+		{
+			name: "duplicated-alias",
+			conf: config{
+				Aliases:  []alias{{Name: "products", Indices: []string{"other"}}},
+				Families: []family{{Name: "products", Version: 2}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicated-index",
+			conf: config{
+				Indices:  []index{{Name: "products-v2"}},
+				Families: []family{{Name: "products", Version: 2}},
+			},
+			wantErr: true,
+		},
+	}
+
+	e := &Eskeeper{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.validateConfigFormat(expandFamilies(tt.conf))
+			if tt.wantErr && err == nil {
+				t.Error("expect error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWithoutMissingPrevious(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	createTmpIndexHelper(t, "family-v2")
+	defer deleteIndexHelper(t, []string{"family-v2"})
+
+	conf := expandFamilies(config{Families: []family{{Name: "family", Version: 3}}})
+	got, err := es.withoutMissingPrevious(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0)
+	for _, ix := range got.Indices {
+		names = append(names, ix.Name)
+	}
+	want := []string{"family-v2", "family-v3"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("want: %v, got: %v", want, names)
+	}
+	if src := got.Indices[1].Reindex.Source; src != "family-v2" {
+		t.Errorf("want reindex source: family-v2, got: %v", src)
+	}
+}
+
+func TestWithoutMissingPreviousEmpty(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// family starts at version 3 on empty cluster.
+	conf := expandFamilies(config{Families: []family{{Name: "family-empty", Version: 3}}})
+	got, err := es.withoutMissingPrevious(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []index{{Name: "family-empty-v3"}}
+	if !reflect.DeepEqual(got.Indices, want) {
+		t.Errorf("want: %+v, got: %+v", want, got.Indices)
+	}
+}
@@ -0,0 +1,293 @@
+package eskeeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/gofrs/uuid"
+)
+
+// ilmPolicy is an index lifecycle management policy.
+type ilmPolicy struct {
+	Name   string `json:"name"`
+	Policy string `json:"policy"` // path to request body json
+}
+
+// lifecycle attaches ILM policy to index with index.lifecycle.* settings.
+type lifecycle struct {
+	Policy        string `json:"policy"`
+	RolloverAlias string `json:"rolloverAlias,omitempty"`
+}
+
+func validateILMPolicy(p ilmPolicy) error {
+	if p.Name == "" {
+		return errors.New("ilm policy name is empty")
+	}
+	if p.Policy == "" {
+		return fmt.Errorf("policy file of %v is empty", p.Name)
+	}
+	body, err := readJSON(p.Policy)
+	if err != nil {
+		return fmt.Errorf("policy json is invalid: %w", err)
+	}
+	if _, ok := body["policy"].(map[string]interface{}); !ok {
+		return fmt.Errorf("policy json of %v has no policy field", p.Name)
+	}
+	return nil
+}
+
+// normalizePolicy fills min_age of phases because Elasticsearch returns it with default value.
+func normalizePolicy(policy map[string]interface{}) (interface{}, error) {
+	n := make(map[string]interface{}, len(policy))
+	for k, v := range policy {
+		n[k] = v
+	}
+	if phases, ok := policy["phases"].(map[string]interface{}); ok {
+		np := make(map[string]interface{}, len(phases))
+		for name, v := range phases {
+			phase, ok := v.(map[string]interface{})
+			if !ok {
+				np[name] = v
+				continue
+			}
+			p := make(map[string]interface{}, len(phase)+1)
+			for k, v := range phase {
+				p[k] = v
+			}
+			if _, ok := p["min_age"]; !ok {
+				p["min_age"] = "0ms"
+			}
+			np[name] = p
+		}
+		n["phases"] = np
+	}
+	return normalizeJSON(n)
+}
+
+func equalPolicy(live, want map[string]interface{}) (bool, error) {
+	l, err := normalizePolicy(live)
+	if err != nil {
+		return false, err
+	}
+	w, err := normalizePolicy(want)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(l, w), nil
+}
+
+// livePolicy returns policy field of ILM policy in Elasticsearch. nil means not found.
+func (c *esclient) livePolicy(ctx context.Context, name string) (map[string]interface{}, error) {
+	get := c.client.ILM.GetLifecycle
+	res, err := get(get.WithPolicy(name), get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get ilm policy %v: %w", name, err)
+	}
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get ilm policy %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get ilm policy [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+
+	got := make(map[string]struct {
+		Policy map[string]interface{} `json:"policy"`
+	}, 0)
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal ilm policy json: %w", err)
+	}
+	p, ok := got[name]
+	if !ok {
+		return nil, nil
+	}
+	return p.Policy, nil
+}
+
+func (c *esclient) putPolicy(ctx context.Context, name string, body map[string]interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal ilm policy json: %w", err)
+	}
+
+	put := c.client.ILM.PutLifecycle
+	res, err := put(name, put.WithBody(bytes.NewReader(b)), put.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("put ilm policy %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to put ilm policy [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to put ilm policy [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (c *esclient) deletePolicy(ctx context.Context, name string) error {
+	delete := c.client.ILM.DeleteLifecycle
+	res, err := delete(name, delete.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("delete ilm policy: %w", err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to delete ilm policy [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to delete ilm policy [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// diffPolicy returns action of policy change. empty action means up to date.
+func (c *esclient) diffPolicy(ctx context.Context, p ilmPolicy) (string, map[string]interface{}, error) {
+	want, err := readJSON(p.Policy)
+	if err != nil {
+		return "", nil, err
+	}
+	live, err := c.livePolicy(ctx, p.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	if live == nil {
+		return "create", want, nil
+	}
+	wantPolicy, _ := want["policy"].(map[string]interface{})
+	ok, err := equalPolicy(live, wantPolicy)
+	if err != nil {
+		return "", nil, fmt.Errorf("compare ilm policy: %w", err)
+	}
+	if ok {
+		return "", want, nil
+	}
+	return "update", want, nil
+}
+
+func (c *esclient) syncPolicies(ctx context.Context, conf config) error {
+	for _, p := range conf.ILMPolicies {
+		action, body, err := c.diffPolicy(ctx, p)
+		if err != nil {
+			c.logf("[fail] ilmPolicy: %v\n", p.Name)
+			return fmt.Errorf("sync ilm policy: %w", err)
+		}
+		if action != "" {
+			err = c.putPolicy(ctx, p.Name, body)
+			if err != nil {
+				c.logf("[fail] ilmPolicy: %v\n", p.Name)
+				return fmt.Errorf("sync ilm policy: %w", err)
+			}
+		}
+		c.logf("[synced] ilmPolicy: %v\n", p.Name)
+	}
+	return nil
+}
+
+func lifecycleSettings(l lifecycle) map[string]interface{} {
+	settings := map[string]interface{}{
+		"index.lifecycle.name": l.Policy,
+	}
+	if l.RolloverAlias != "" {
+		settings["index.lifecycle.rollover_alias"] = l.RolloverAlias
+	}
+	return settings
+}
+
+// syncLifecycle sets index.lifecycle.* settings when they differ from the live index.
+func (c *esclient) syncLifecycle(ctx context.Context, index index) error {
+	if index.Lifecycle == nil {
+		return nil
+	}
+	putSettings := c.client.Indices.PutSettings
+
+	dynamic, _, err := c.diffSettingsWithLive(ctx, index, lifecycleSettings(*index.Lifecycle))
+	if err != nil {
+		return fmt.Errorf("update %v lifecycle: %w", index.Name, err)
+	}
+	if len(dynamic) == 0 {
+		return nil
+	}
+
+	j, err := json.Marshal(dynamic)
+	if err != nil {
+		return fmt.Errorf("marshal settings json: %w", err)
+	}
+	res, err := putSettings(
+		bytes.NewReader(j),
+		putSettings.WithIndex(index.Name),
+		putSettings.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("update %v lifecycle: %w", index.Name, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("update %v lifecycle: %w", index.Name, err)
+		}
+		return fmt.Errorf("update %v lifecycle: %v", index.Name, string(body))
+	}
+	c.logf("[updated] lifecycle: %v (%v)\n", index.Name, strings.Join(sortedKeys(dynamic), ", "))
+	return nil
+}
+
+func (c *esclient) preCheckPolicy(ctx context.Context, p ilmPolicy) error {
+	u2, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("generate UUID for pre-check: %w", err)
+	}
+	name := fmt.Sprintf("eskeeper-%s", u2.String())
+
+	body, err := readJSON(p.Policy)
+	if err != nil {
+		return fmt.Errorf("pre-check: %w", err)
+	}
+	err = c.putPolicy(ctx, name, body)
+	if err != nil {
+		return fmt.Errorf("pre-check: pre create using random name ilm policy: %w", err)
+	}
+	err = c.deletePolicy(ctx, name)
+	if err != nil {
+		return fmt.Errorf("pre-check: delete pre-created ilm policy: %w", err)
+	}
+	return nil
+}
+
+// preCheckPolicies checks policy bodies.
+func (c *esclient) preCheckPolicies(ctx context.Context, conf config) error {
+	for _, p := range conf.ILMPolicies {
+		err := c.preCheckPolicy(ctx, p)
+		if err != nil {
+			c.logf("[fail] ilmPolicy: %v\n", p.Name)
+			return err
+		}
+		c.logf("[pass] ilmPolicy: %v\n", p.Name)
+	}
+	return nil
+}
+
+func (c *esclient) postCheckPolicies(ctx context.Context, conf config) error {
+	for _, p := range conf.ILMPolicies {
+		live, err := c.livePolicy(ctx, p.Name)
+		if err != nil {
+			c.logf("[fail] ilmPolicy: %v\n", p.Name)
+			return fmt.Errorf("post-check: check ilm policy %v exist: %w", p.Name, err)
+		}
+		if live == nil {
+			c.logf("[fail] ilmPolicy: %v\n", p.Name)
+			return fmt.Errorf("post-check: ilm policy %v is not found", p.Name)
+		}
+		c.logf("[pass] ilmPolicy: %v\n", p.Name)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestEqualPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		live map[string]interface{}
+		want map[string]interface{}
+		ok   bool
+	}{
+		{
+			name: "default-min-age",
+			live: map[string]interface{}{
+				"phases": map[string]interface{}{
+					"hot": map[string]interface{}{
+						"min_age": "0ms",
+						"actions": map[string]interface{}{},
+					},
+				},
+			},
+			want: map[string]interface{}{
+				"phases": map[string]interface{}{
+					"hot": map[string]interface{}{
+						"actions": map[string]interface{}{},
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			name: "different-min-age",
+			live: map[string]interface{}{
+				"phases": map[string]interface{}{
+					"delete": map[string]interface{}{"min_age": "30d"},
+				},
+			},
+			want: map[string]interface{}{
+				"phases": map[string]interface{}{
+					"delete": map[string]interface{}{"min_age": "90d"},
+				},
+			},
+			ok: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := equalPolicy(tt.live, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok {
+				t.Errorf("want: %v, got: %v", tt.ok, ok)
+			}
+		})
+	}
+}
+
+func TestSyncPolicies(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	conf := config{
+		ILMPolicies: []ilmPolicy{
+			{Name: "eskeeper-retention", Policy: "testdata/ilmPolicy.json"},
+		},
+		Indices: []index{
+			{
+				Name:      "ilm-v1",
+				Lifecycle: &lifecycle{Policy: "eskeeper-retention", RolloverAlias: "ilm"},
+			},
+		},
+	}
+	createTmpIndexHelper(t, "ilm-v1")
+	defer es.deletePolicy(ctx, "eskeeper-retention")
+	defer deleteIndexHelper(t, []string{"ilm-v1"})
+
+	want := []Change{
+		{Resource: "ilmPolicy", Name: "eskeeper-retention", Action: "create"},
+		{Resource: "index", Name: "ilm-v1", Action: "update", Detail: "lifecycle: eskeeper-retention"},
+	}
+	got, err := es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %+v\ngot : %+v\n", want, got)
+	}
+
+	if err := es.preCheckPolicies(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.syncPolicies(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.syncLifecycle(ctx, conf.Indices[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.postCheckPolicies(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want no changes after sync, got: %+v", got)
+	}
+}
+
+func TestValidateConfigFormatUndeclaredPolicy(t *testing.T) {
+	e := &Eskeeper{}
+	conf := config{
+		Indices: []index{
+			{Name: "ilm-undeclared-v1", Lifecycle: &lifecycle{Policy: "undeclared"}},
+		},
+	}
+	if err := e.validateConfigFormat(conf); err == nil {
+		t.Error("expect error")
+	}
+}
@@ -1,10 +1,15 @@
 package eskeeper
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 )
 
 func (c *esclient) existIndex(ctx context.Context, index string) (bool, error) {
@@ -23,6 +28,92 @@ func (c *esclient) existIndex(ctx context.Context, index string) (bool, error) {
 	return false, nil
 }
 
+// catIndex returns columns of cat indices API for the index.
+func (c *esclient) catIndex(ctx context.Context, index string, h ...string) (map[string]string, error) {
+	rows, err := c.catIndices(ctx, index, h...)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("cat index of %v is not found", index)
+	}
+	return rows[0], nil
+}
+
+// catIndices returns columns of cat indices API for indices matching pattern.
+func (c *esclient) catIndices(ctx context.Context, pattern string, h ...string) ([]map[string]string, error) {
+	cat := c.client.Cat.Indices
+	res, err := cat(
+		cat.WithIndex(pattern),
+		cat.WithContext(ctx),
+		cat.WithFormat("json"),
+		cat.WithBytes("b"),
+		cat.WithH(h...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cat index: %w", err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cat index: %w", err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to cat index [index=%v, statusCode=%v, res=%v]", pattern, res.StatusCode, string(body))
+	}
+
+	var rows []map[string]string
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("unmarshal cat index: %w", err)
+	}
+	return rows, nil
+}
+
+// indexStatus returns "open" or "close".
+func (c *esclient) indexStatus(ctx context.Context, index string) (string, error) {
+	row, err := c.catIndex(ctx, index, "status")
+	if err != nil {
+		return "", fmt.Errorf("get index status: %w", err)
+	}
+	return row["status"], nil
+}
+
+// staysClosed reports whether the index is closed and is declared to stay closed.
+// Mappings & settings of closed index cannot be updated.
+func (c *esclient) staysClosed(ctx context.Context, index index) (bool, error) {
+	if index.Status != "close" {
+		return false, nil
+	}
+	status, err := c.indexStatus(ctx, index.Name)
+	if err != nil {
+		return false, err
+	}
+	return status == "close", nil
+}
+
+// indexSize returns document count and store size in bytes.
+func (c *esclient) indexSize(ctx context.Context, index string) (int64, int64, error) {
+	row, err := c.catIndex(ctx, index, "docs.count", "store.size")
+	if err != nil {
+		return 0, 0, fmt.Errorf("get index size: %w", err)
+	}
+
+	// closed index has no stats.
+	var docs, size int64
+	if v := row["docs.count"]; v != "" {
+		docs, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse docs.count: %w", err)
+		}
+	}
+	if v := row["store.size"]; v != "" {
+		size, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse store.size: %w", err)
+		}
+	}
+	return docs, size, nil
+}
+
 func (c *esclient) syncIndex(ctx context.Context, index index) error {
 	create := c.client.Indices.Create
 	ok, err := c.existIndex(ctx, index.Name)
@@ -32,9 +123,13 @@ func (c *esclient) syncIndex(ctx context.Context, index index) error {
 
 	// index dose not exist.
 	if !ok {
-		f, err := os.Open(index.Mapping)
+		if index.From != nil {
+			return c.createFrom(ctx, index)
+		}
+
+		f, err := indexBody(index)
 		if err != nil {
-			return fmt.Errorf("open mapping file: %w", err)
+			return err
 		}
 
 		res, err := create(
@@ -67,14 +162,34 @@ func (c *esclient) syncIndex(ctx context.Context, index index) error {
 			return fmt.Errorf("reindex (%s -> %s) conf is invalid. Make sure %s index exists", index.Reindex.Source, index.Reindex.Source, index.Name)
 		}
 
-		err = c.reindex(ctx, index.Name, index.Reindex)
+		return c.reindexAndVerify(ctx, index.Name, index.Reindex)
+	}
+
+	// index already exists.
+
+	// Since downtime may occur when switching aliases, only open is processed before switching aliases.
+	if index.Status != "close" {
+		err = c.openIndex(ctx, index)
 		if err != nil {
-			return fmt.Errorf("reindex (%s -> %s)", index.Reindex.Source, index.Name)
+			return fmt.Errorf("open index: %w", err)
 		}
-		return nil
 	}
 
-	// index already exists.
+	// mappings -------
+	if hasIndexConfig(index) {
+		closed, err := c.staysClosed(ctx, index)
+		if err != nil {
+			return err
+		}
+		if closed {
+			c.logf("[skip] mappings & settings: %v (index is closed)\n", index.Name)
+		} else {
+			err = c.updateIndex(ctx, index)
+			if err != nil {
+				return err
+			}
+		}
+	}
 
 	// reindex -------
 	if index.Reindex.Source != "" && index.Reindex.On == "always" {
@@ -85,23 +200,48 @@ func (c *esclient) syncIndex(ctx context.Context, index index) error {
 		if !ok {
 			return fmt.Errorf("reindex (%s -> %s) conf is invalid. Make sure %s index exists", index.Reindex.Source, index.Reindex.Source, index.Name)
 		}
-		err = c.reindex(ctx, index.Name, index.Reindex)
+		return c.reindexAndVerify(ctx, index.Name, index.Reindex)
+	}
+
+	// dest that failed verification on previous run must not be switched to.
+	if index.Reindex.Source != "" {
+		return c.retryVerify(ctx, index.Name, index.Reindex)
+	}
+	return nil
+}
+
+// indexBody returns request body of create index API.
+// Mapping file is sent as it is unless settings of index override it.
+func indexBody(index index) (io.Reader, error) {
+	if len(index.Settings) == 0 {
+		f, err := os.Open(index.Mapping)
 		if err != nil {
-			return fmt.Errorf("reindex (%s -> %s)", index.Reindex.Source, index.Name)
+			return nil, fmt.Errorf("open mapping file: %w", err)
 		}
-		return nil
+		return f, nil
 	}
 
-	// Since downtime may occur when switching aliases, only open is processed before switching aliases.
-	// TODO: refactoring.
-	if index.Status == "close" {
-		return nil
+	body := make(map[string]interface{}, 0)
+	if index.Mapping != "" {
+		b, err := ioutil.ReadFile(index.Mapping)
+		if err != nil {
+			return nil, fmt.Errorf("open mapping file: %w", err)
+		}
+		if err := json.Unmarshal(b, &body); err != nil {
+			return nil, fmt.Errorf("unmarshal mapping json: %w", err)
+		}
 	}
-	err = c.openIndex(ctx, index)
+	conf, err := readIndexMapping(index)
 	if err != nil {
-		return fmt.Errorf("open index: %w", err)
+		return nil, err
 	}
-	return nil
+	body["settings"] = conf.Settings
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, fmt.Errorf("build index body: %w", err)
+	}
+	return &buf, nil
 }
 
 func (c *esclient) deleteIndex(ctx context.Context, index string) error {
@@ -120,6 +260,66 @@ func (c *esclient) deleteIndex(ctx context.Context, index string) error {
 	return nil
 }
 
+// checkDeletable checks guard rails before deleting index.
+func (c *esclient) checkDeletable(ctx context.Context, index string) error {
+	if !c.allowDelete {
+		return fmt.Errorf("deleting index %v is not allowed. use allow-delete option", index)
+	}
+
+	aliases, err := c.indexAliases(ctx, index)
+	if err != nil {
+		return err
+	}
+	if len(aliases) > 0 {
+		return fmt.Errorf("index %v is still referenced by aliases %v", index, strings.Join(aliases, ", "))
+	}
+
+	docs, size, err := c.indexSize(ctx, index)
+	if err != nil {
+		return err
+	}
+	if c.deleteMaxDocs > 0 && docs > c.deleteMaxDocs {
+		return fmt.Errorf("index %v has %v docs over delete threshold %v", index, docs, c.deleteMaxDocs)
+	}
+	if c.deleteMaxBytes > 0 && size > c.deleteMaxBytes {
+		return fmt.Errorf("index %v has %v bytes over delete threshold %v", index, size, c.deleteMaxBytes)
+	}
+	return nil
+}
+
+// syncAbsentIndices deletes indices with absent state.
+// It runs after switching aliases so that an index removed from aliases in the same run can be deleted.
+func (c *esclient) syncAbsentIndices(ctx context.Context, conf config) error {
+	for _, index := range conf.Indices {
+		if index.State != "absent" {
+			continue
+		}
+
+		ok, err := c.existIndex(ctx, index.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			c.logf("[skip] index %v already deleted\n", index.Name)
+			continue
+		}
+
+		err = c.checkDeletable(ctx, index.Name)
+		if err != nil {
+			c.logf("[fail] index: %v\n", index.Name)
+			return fmt.Errorf("delete index: %w", err)
+		}
+
+		err = c.deleteIndex(ctx, index.Name)
+		if err != nil {
+			c.logf("[fail] index: %v\n", index.Name)
+			return err
+		}
+		c.logf("[deleted] index: %v\n", index.Name)
+	}
+	return nil
+}
+
 func (c *esclient) syncCloseStatus(ctx context.Context, conf config) error {
 	for _, index := range conf.Indices {
 		if index.Status == "close" {
@@ -187,7 +387,25 @@ func (c *esclient) openIndex(ctx context.Context, index index) error {
 
 func (c *esclient) syncIndices(ctx context.Context, conf config) error {
 	for _, index := range conf.Indices {
-		err := c.syncIndex(ctx, index)
+		if index.State == "absent" {
+			continue
+		}
+		err := c.unblockIndex(ctx, index)
+		if err != nil {
+			c.logf("[fail] index: %v\n", index.Name)
+			return fmt.Errorf("sync index: %w", err)
+		}
+		err = c.syncIndex(ctx, index)
+		if err != nil {
+			c.logf("[fail] index: %v\n", index.Name)
+			return fmt.Errorf("sync index: %w", err)
+		}
+		err = c.syncLifecycle(ctx, index)
+		if err != nil {
+			c.logf("[fail] index: %v\n", index.Name)
+			return fmt.Errorf("sync index: %w", err)
+		}
+		err = c.blockIndex(ctx, index)
 		if err != nil {
 			c.logf("[fail] index: %v\n", index.Name)
 			return fmt.Errorf("sync index: %w", err)
@@ -151,6 +151,25 @@ func TestSyncIndices(t *testing.T) {
 				deleteIndexHelper(tb, []string{"reindex-v0", "reindex-v1"})
 			},
 		},
+		{
+			name: "update-closed",
+			conf: config{
+				Indices: []index{
+					{
+						Name:    "update-closed-v1",
+						Mapping: "testdata/updateIndex.json",
+						Status:  "close",
+					},
+				},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "update-closed-v1")
+				closeIndexHelper(tb, "update-closed-v1")
+			},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"update-closed-v1"})
+			},
+		},
 		{
 			name: "reindex-already-exists",
 			conf: config{
@@ -319,3 +338,116 @@ func TestSyncCloseStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestSyncAbsentIndices(t *testing.T) {
+	tests := []struct {
+		name          string
+		conf          config
+		allowDelete   bool
+		deleteMaxDocs int64
+		setup         func(tb testing.TB)
+		wantErr       bool
+	}{
+		{
+			name: "simple",
+			conf: config{
+				Indices: []index{
+					{
+						Name:  "absent-v1",
+						State: "absent",
+					},
+				},
+			},
+			allowDelete: true,
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "absent-v1")
+			},
+		},
+		{
+			name: "already-deleted",
+			conf: config{
+				Indices: []index{
+					{
+						Name:  "absent-not-found",
+						State: "absent",
+					},
+				},
+			},
+			allowDelete: true,
+		},
+		{
+			name: "not-allowed",
+			conf: config{
+				Indices: []index{
+					{
+						Name:  "absent-v2",
+						State: "absent",
+					},
+				},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "absent-v2")
+			},
+			wantErr: true,
+		},
+		{
+			name: "referenced-by-alias",
+			conf: config{
+				Indices: []index{
+					{
+						Name:  "absent-v3",
+						State: "absent",
+					},
+				},
+			},
+			allowDelete: true,
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "absent-v3")
+				createTmpAliasHelper(tb, "absent-alias", "absent-v3")
+			},
+			wantErr: true,
+		},
+		{
+			name: "over-max-docs",
+			conf: config{
+				Indices: []index{
+					{
+						Name:  "absent-v4",
+						State: "absent",
+					},
+				},
+			},
+			allowDelete:   true,
+			deleteMaxDocs: 1,
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "absent-v4")
+				postDocHelper(tb, "absent-v4")
+				postDocHelper(tb, "absent-v4")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es, err := newEsClient([]string{url}, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			es.allowDelete = tt.allowDelete
+			es.deleteMaxDocs = tt.deleteMaxDocs
+
+			ctx := context.Background()
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			err = es.syncAbsentIndices(ctx, tt.conf)
+			if tt.wantErr && err == nil {
+				t.Error("expect error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
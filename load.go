@@ -0,0 +1,283 @@
+package eskeeper
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// position is where a resource is declared. It is used to report duplicates across files.
+type position struct {
+	file string
+	line int
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("%s:%d", p.file, p.line)
+}
+
+// document is a YAML document in multi-document YAML.
+type document struct {
+	body []byte
+	line int // first line in the file
+}
+
+var documentSeparator = regexp.MustCompile(`^---(\s.*)?$`)
+
+// splitDocuments splits multi-document YAML separated by "---".
+func splitDocuments(b []byte) []document {
+	docs := make([]document, 0)
+	var buf bytes.Buffer
+	start, line := 1, 0
+
+	flush := func() {
+		if len(bytes.TrimSpace(buf.Bytes())) > 0 {
+			docs = append(docs, document{body: append([]byte{}, buf.Bytes()...), line: start})
+		}
+		buf.Reset()
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	s.Buffer(make([]byte, 0, 64*1024), len(b)+1)
+	for s.Scan() {
+		line++
+		if documentSeparator.Match(s.Bytes()) {
+			flush()
+			start = line + 1
+			continue
+		}
+		buf.Write(s.Bytes())
+		buf.WriteByte('\n')
+	}
+	flush()
+	return docs
+}
+
+// mappingValues returns key-value pairs of mapping node.
+// A mapping with a single key is parsed as MappingValueNode.
+func mappingValues(node ast.Node) []*ast.MappingValueNode {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}
+	}
+	return nil
+}
+
+// declaredName is "name" of an entry in top level lists like index or alias.
+type declaredName struct {
+	kind string
+	name string
+	line int
+}
+
+// declaredNames returns names in top level lists in order of appearance.
+func declaredNames(b []byte) ([]declaredName, error) {
+	f, err := parser.ParseBytes(b, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]declaredName, 0)
+	for _, doc := range f.Docs {
+		for _, kind := range mappingValues(doc.Body) {
+			seq, ok := kind.Value.(*ast.SequenceNode)
+			if !ok {
+				continue
+			}
+			for _, item := range seq.Values {
+				for _, kv := range mappingValues(item) {
+					if kv.Key.GetToken().Value != "name" || kv.Value == nil {
+						continue
+					}
+					tk := kv.Value.GetToken()
+					names = append(names, declaredName{
+						kind: kind.Key.GetToken().Value,
+						name: tk.Value,
+						line: tk.Position.Line,
+					})
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// mergeConfig appends all lists in src to dst.
+func mergeConfig(dst, src config) config {
+	d := reflect.ValueOf(&dst).Elem()
+	s := reflect.ValueOf(src)
+	for i := 0; i < d.NumField(); i++ {
+		if d.Field(i).Kind() != reflect.Slice || s.Field(i).Len() == 0 {
+			continue
+		}
+		d.Field(i).Set(reflect.AppendSlice(d.Field(i), s.Field(i)))
+	}
+
+	if len(src.Envs) > 0 && dst.Envs == nil {
+		dst.Envs = make(map[string]overlay, len(src.Envs))
+	}
+	for name, o := range src.Envs {
+		dst.Envs[name] = mergeOverlay(dst.Envs[name], o)
+	}
+	return dst
+}
+
+// resolvePath resolves relative path against dir.
+func resolvePath(dir, path string) string {
+	if dir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// resolvePaths resolves relative paths of files referred from config like mapping files against dir.
+func resolvePaths(conf config, dir string) config {
+	for i := range conf.Indices {
+		conf.Indices[i].Mapping = resolvePath(dir, conf.Indices[i].Mapping)
+	}
+	for i := range conf.Aliases {
+		if r := conf.Aliases[i].Rollover; r != nil {
+			r.Mapping = resolvePath(dir, r.Mapping)
+		}
+	}
+	for i := range conf.Families {
+		conf.Families[i].Mapping = resolvePath(dir, conf.Families[i].Mapping)
+	}
+	for i := range conf.IndexTemplates {
+		conf.IndexTemplates[i].Template = resolvePath(dir, conf.IndexTemplates[i].Template)
+	}
+	for i := range conf.ComponentTemplates {
+		conf.ComponentTemplates[i].Template = resolvePath(dir, conf.ComponentTemplates[i].Template)
+	}
+	for i := range conf.ILMPolicies {
+		conf.ILMPolicies[i].Policy = resolvePath(dir, conf.ILMPolicies[i].Policy)
+	}
+	for i := range conf.Pipelines {
+		conf.Pipelines[i].Pipeline = resolvePath(dir, conf.Pipelines[i].Pipeline)
+	}
+	for i := range conf.Scripts {
+		conf.Scripts[i].Source = resolvePath(dir, conf.Scripts[i].Source)
+	}
+	for i := range conf.SLMPolicies {
+		conf.SLMPolicies[i].Policy = resolvePath(dir, conf.SLMPolicies[i].Policy)
+	}
+	for name, o := range conf.Envs {
+		o.Config = resolvePaths(o.Config, dir)
+		conf.Envs[name] = o
+	}
+	return conf
+}
+
+// configLoader merges YAML documents into one config and detects duplicated names.
+type configLoader struct {
+	conf     config
+	declared map[string]position
+}
+
+func newConfigLoader() *configLoader {
+	return &configLoader{
+		declared: make(map[string]position, 0),
+	}
+}
+
+// load merges documents of file. name is used in error messages.
+// Relative file paths in documents are resolved against dir.
+func (l *configLoader) load(name, dir string, b []byte) error {
+	for _, doc := range splitDocuments(b) {
+		conf := config{}
+		if err := yaml.Unmarshal(doc.body, &conf); err != nil {
+			return fmt.Errorf("%v:%v: %w", name, doc.line, err)
+		}
+
+		names, err := declaredNames(doc.body)
+		if err != nil {
+			return fmt.Errorf("%v:%v: %w", name, doc.line, err)
+		}
+		for _, n := range names {
+			key := n.kind + "/" + n.name
+			pos := position{file: name, line: doc.line + n.line - 1}
+			if first, ok := l.declared[key]; ok {
+				return fmt.Errorf("duplicated %v name %v (%v and %v)", n.kind, n.name, first, pos)
+			}
+			l.declared[key] = pos
+		}
+
+		l.conf = mergeConfig(l.conf, resolvePaths(conf, dir))
+	}
+	return nil
+}
+
+// config returns merged config with overlay of env and expanded families.
+func (l *configLoader) config(env string) (config, error) {
+	conf, err := applyEnv(l.conf, env)
+	if err != nil {
+		return conf, err
+	}
+	return expandFamilies(conf), nil
+}
+
+// configFiles expands directories into *.yaml and *.yml files sorted by name.
+// Sub directories are not walked.
+func configFiles(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(p)
+		if err != nil {
+			return nil, fmt.Errorf("read config dir: %w", err)
+		}
+		found := 0
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			files = append(files, filepath.Join(p, e.Name()))
+			found++
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("no yaml files in %v", p)
+		}
+	}
+	return files, nil
+}
+
+// loadConfig merges config files, directories of yaml files and multi-document YAML into one config.
+// File paths in config are relative to the config file that declares them.
+func loadConfig(paths []string, env string) (config, error) {
+	files, err := configFiles(paths)
+	if err != nil {
+		return config{}, err
+	}
+
+	l := newConfigLoader()
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return config{}, fmt.Errorf("read config: %w", err)
+		}
+		if err := l.load(f, filepath.Dir(f), b); err != nil {
+			return config{}, err
+		}
+	}
+	return l.config(env)
+}
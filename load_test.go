@@ -0,0 +1,157 @@
+package eskeeper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []document
+	}{
+		{
+			name: "single",
+			yaml: "index:\n  - name: a\n",
+			want: []document{
+				{body: []byte("index:\n  - name: a\n"), line: 1},
+			},
+		},
+		{
+			name: "multi",
+			yaml: "---\nindex:\n  - name: a\n--- # b\nalias:\n  - name: b\n",
+			want: []document{
+				{body: []byte("index:\n  - name: a\n"), line: 2},
+				{body: []byte("alias:\n  - name: b\n"), line: 5},
+			},
+		},
+		{
+			name: "empty",
+			yaml: "---\n\n---\n",
+			want: []document{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitDocuments([]byte(tt.yaml))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want: %+v, got: %+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		want    config
+		wantErr string
+	}{
+		{
+			name:  "dir",
+			paths: []string{"testdata/multi"},
+			want: config{
+				Indices: []index{
+					{Name: "logs-v1", Mapping: "testdata/test.json"},
+					{Name: "search-v1", Mapping: "testdata/test.json"},
+				},
+				Aliases: []alias{
+					{Name: "search", Indices: []string{"search-v1"}},
+				},
+			},
+		},
+		{
+			name:  "files",
+			paths: []string{"testdata/multi/search.yaml", "testdata/multi/logs.yml"},
+			want: config{
+				Indices: []index{
+					{Name: "search-v1", Mapping: "testdata/test.json"},
+					{Name: "logs-v1", Mapping: "testdata/test.json"},
+				},
+				Aliases: []alias{
+					{Name: "search", Indices: []string{"search-v1"}},
+				},
+			},
+		},
+		{
+			name:    "duplicated",
+			paths:   []string{"testdata/es.yaml", "testdata/es.duplicate.yaml"},
+			wantErr: "duplicated index name test-v1 (testdata/es.yaml:2 and testdata/es.duplicate.yaml:6)",
+		},
+		{
+			name:    "not-found",
+			paths:   []string{"testdata/not-found.yaml"},
+			wantErr: "read config: stat testdata/not-found.yaml: no such file or directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadConfig(tt.paths, "")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("want error: %v, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	tests := []struct {
+		name string
+		conf config
+		dir  string
+		want config
+	}{
+		{
+			name: "relative",
+			conf: config{
+				Indices:        []index{{Name: "a", Mapping: "mappings/a.json"}},
+				IndexTemplates: []template{{Name: "t", Template: "t.json"}},
+				Aliases: []alias{
+					{Name: "logs", Rollover: &rollover{Mapping: "logs.json"}},
+				},
+			},
+			dir: "example",
+			want: config{
+				Indices:        []index{{Name: "a", Mapping: "example/mappings/a.json"}},
+				IndexTemplates: []template{{Name: "t", Template: "example/t.json"}},
+				Aliases: []alias{
+					{Name: "logs", Rollover: &rollover{Mapping: "example/logs.json"}},
+				},
+			},
+		},
+		{
+			name: "absolute",
+			conf: config{Indices: []index{{Name: "a", Mapping: "/etc/a.json"}}},
+			dir:  "example",
+			want: config{Indices: []index{{Name: "a", Mapping: "/etc/a.json"}}},
+		},
+		{
+			name: "working-directory",
+			conf: config{Indices: []index{{Name: "a", Mapping: "a.json"}}},
+			dir:  "",
+			want: config{Indices: []index{{Name: "a", Mapping: "a.json"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolvePaths(tt.conf, tt.dir)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+		})
+	}
+}
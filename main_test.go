@@ -27,6 +27,7 @@ func TestMain(m *testing.M) {
 			"ES_JAVA_OPTS=-Xms512m -Xmx512m",
 			"discovery.type=single-node",
 			"node.name=es01",
+			"path.repo=/tmp/eskeeper-backup",
 		},
 	)
 	if err != nil {
@@ -153,6 +154,46 @@ func deleteIndexHelper(tb testing.TB, indices []string) {
 	}
 }
 
+func deleteRepositoryHelper(tb testing.TB, repositories []string) {
+	tb.Helper()
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	d := es.client.Snapshot.DeleteRepository
+	res, err := d(repositories)
+	if err != nil {
+		tb.Fatalf("delete snapshot repository: %v", err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			tb.Fatalf("failed to delete snapshot repository [statusCode=%v]", res.StatusCode)
+		}
+		tb.Fatalf("failed to delete snapshot repository [statusCode=%v, res=%v]", res.StatusCode, string(body))
+	}
+}
+
+func deleteSLMPolicyHelper(tb testing.TB, policy string) {
+	tb.Helper()
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	d := es.client.SlmDeleteLifecycle
+	res, err := d(policy)
+	if err != nil {
+		tb.Fatalf("delete slm policy: %v", err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			tb.Fatalf("failed to delete slm policy [policy=%v, statusCode=%v]", policy, res.StatusCode)
+		}
+		tb.Fatalf("failed to delete slm policy [policy=%v, statusCode=%v, res=%v]", policy, res.StatusCode, string(body))
+	}
+}
+
 func closeIndexHelper(tb testing.TB, index string) {
 	conf := elasticsearch.Config{
 		Addresses: []string{url},
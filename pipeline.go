@@ -0,0 +1,246 @@
+package eskeeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/gofrs/uuid"
+)
+
+// ingestPipeline is an ingest pipeline. Samples are _source of documents used by pre-check simulation.
+type ingestPipeline struct {
+	Name     string                   `json:"name"`
+	Pipeline string                   `json:"pipeline"` // path to request body json
+	Samples  []map[string]interface{} `json:"samples,omitempty"`
+}
+
+func validatePipeline(p ingestPipeline) error {
+	if p.Name == "" {
+		return errors.New("pipeline name is empty")
+	}
+	if p.Pipeline == "" {
+		return fmt.Errorf("pipeline file of %v is empty", p.Name)
+	}
+	_, err := readJSON(p.Pipeline)
+	if err != nil {
+		return fmt.Errorf("pipeline json is invalid: %w", err)
+	}
+	return nil
+}
+
+// livePipeline returns pipeline body in Elasticsearch. nil means not found.
+func (c *esclient) livePipeline(ctx context.Context, name string) (map[string]interface{}, error) {
+	get := c.client.Ingest.GetPipeline
+	res, err := get(get.WithPipelineID(name), get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get pipeline %v: %w", name, err)
+	}
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get pipeline %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get pipeline [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+
+	got := make(map[string]map[string]interface{}, 0)
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal pipeline json: %w", err)
+	}
+	return got[name], nil
+}
+
+func (c *esclient) putPipeline(ctx context.Context, name string, body map[string]interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal pipeline json: %w", err)
+	}
+
+	put := c.client.Ingest.PutPipeline
+	res, err := put(name, bytes.NewReader(b), put.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("put pipeline %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to put pipeline [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to put pipeline [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (c *esclient) deletePipeline(ctx context.Context, name string) error {
+	delete := c.client.Ingest.DeletePipeline
+	res, err := delete(name, delete.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("delete pipeline: %w", err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to delete pipeline [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to delete pipeline [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// diffPipeline returns action of pipeline change. empty action means up to date.
+func (c *esclient) diffPipeline(ctx context.Context, p ingestPipeline) (string, map[string]interface{}, error) {
+	want, err := readJSON(p.Pipeline)
+	if err != nil {
+		return "", nil, err
+	}
+	live, err := c.livePipeline(ctx, p.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	if live == nil {
+		return "create", want, nil
+	}
+	l, err := normalizeJSON(live)
+	if err != nil {
+		return "", nil, fmt.Errorf("compare pipeline: %w", err)
+	}
+	w, err := normalizeJSON(want)
+	if err != nil {
+		return "", nil, fmt.Errorf("compare pipeline: %w", err)
+	}
+	if reflect.DeepEqual(l, w) {
+		return "", want, nil
+	}
+	return "update", want, nil
+}
+
+func (c *esclient) syncPipelines(ctx context.Context, conf config) error {
+	for _, p := range conf.Pipelines {
+		action, body, err := c.diffPipeline(ctx, p)
+		if err != nil {
+			c.logf("[fail] pipeline: %v\n", p.Name)
+			return fmt.Errorf("sync pipeline: %w", err)
+		}
+		if action != "" {
+			err = c.putPipeline(ctx, p.Name, body)
+			if err != nil {
+				c.logf("[fail] pipeline: %v\n", p.Name)
+				return fmt.Errorf("sync pipeline: %w", err)
+			}
+		}
+		c.logf("[synced] pipeline: %v\n", p.Name)
+	}
+	return nil
+}
+
+type simulateResponse struct {
+	Docs []struct {
+		Error map[string]interface{} `json:"error"`
+	} `json:"docs"`
+}
+
+// simulatePipeline runs pipeline body against sample documents and returns the first processor error.
+func (c *esclient) simulatePipeline(ctx context.Context, name string, body map[string]interface{}, samples []map[string]interface{}) error {
+	docs := make([]map[string]interface{}, 0, len(samples))
+	for _, s := range samples {
+		docs = append(docs, map[string]interface{}{"_source": s})
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"pipeline": body,
+		"docs":     docs,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal simulate json: %w", err)
+	}
+
+	simulate := c.client.Ingest.Simulate
+	res, err := simulate(bytes.NewReader(b), simulate.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("simulate pipeline %v: %w", name, err)
+	}
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("simulate pipeline %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		return fmt.Errorf("failed to simulate pipeline [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(resBody))
+	}
+
+	got := simulateResponse{}
+	if err := json.Unmarshal(resBody, &got); err != nil {
+		return fmt.Errorf("unmarshal simulate json: %w", err)
+	}
+	for i, d := range got.Docs {
+		if len(d.Error) > 0 {
+			return fmt.Errorf("pipeline %v failed on samples[%d]: %v", name, i, d.Error["reason"])
+		}
+	}
+	return nil
+}
+
+func (c *esclient) preCheckPipeline(ctx context.Context, p ingestPipeline) error {
+	body, err := readJSON(p.Pipeline)
+	if err != nil {
+		return fmt.Errorf("pre-check: %w", err)
+	}
+
+	if len(p.Samples) > 0 {
+		err = c.simulatePipeline(ctx, p.Name, body, p.Samples)
+		if err != nil {
+			return fmt.Errorf("pre-check: %w", err)
+		}
+		return nil
+	}
+
+	// without samples, only processor definitions are checked.
+	u2, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("generate UUID for pre-check: %w", err)
+	}
+	name := fmt.Sprintf("eskeeper-%s", u2.String())
+	err = c.putPipeline(ctx, name, body)
+	if err != nil {
+		return fmt.Errorf("pre-check: pre create using random name pipeline: %w", err)
+	}
+	err = c.deletePipeline(ctx, name)
+	if err != nil {
+		return fmt.Errorf("pre-check: delete pre-created pipeline: %w", err)
+	}
+	return nil
+}
+
+func (c *esclient) preCheckPipelines(ctx context.Context, conf config) error {
+	for _, p := range conf.Pipelines {
+		err := c.preCheckPipeline(ctx, p)
+		if err != nil {
+			c.logf("[fail] pipeline: %v\n", p.Name)
+			return err
+		}
+		c.logf("[pass] pipeline: %v\n", p.Name)
+	}
+	return nil
+}
+
+func (c *esclient) postCheckPipelines(ctx context.Context, conf config) error {
+	for _, p := range conf.Pipelines {
+		live, err := c.livePipeline(ctx, p.Name)
+		if err != nil {
+			c.logf("[fail] pipeline: %v\n", p.Name)
+			return fmt.Errorf("post-check: check pipeline %v exist: %w", p.Name, err)
+		}
+		if live == nil {
+			c.logf("[fail] pipeline: %v\n", p.Name)
+			return fmt.Errorf("post-check: pipeline %v is not found", p.Name)
+		}
+		c.logf("[pass] pipeline: %v\n", p.Name)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPreCheckPipelines(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline ingestPipeline
+		wantErr  bool
+	}{
+		{
+			name:     "without-samples",
+			pipeline: ingestPipeline{Name: "precheck-pipeline", Pipeline: "testdata/pipeline.json"},
+		},
+		{
+			name: "samples",
+			pipeline: ingestPipeline{
+				Name:     "precheck-pipeline",
+				Pipeline: "testdata/pipeline.json",
+				Samples:  []map[string]interface{}{{"title": "Eskeeper"}},
+			},
+		},
+		{
+			name: "broken-sample",
+			pipeline: ingestPipeline{
+				Name:     "precheck-pipeline",
+				Pipeline: "testdata/pipeline.json",
+				Samples:  []map[string]interface{}{{"body": "no title field"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := es.preCheckPipelines(context.Background(), config{Pipelines: []ingestPipeline{tt.pipeline}})
+			if tt.wantErr && err == nil {
+				t.Error("expect error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSyncPipelines(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	conf := config{
+		Pipelines: []ingestPipeline{
+			{Name: "eskeeper-lowercase", Pipeline: "testdata/pipeline.json"},
+		},
+	}
+	defer es.deletePipeline(ctx, "eskeeper-lowercase")
+
+	want := []Change{
+		{Resource: "pipeline", Name: "eskeeper-lowercase", Action: "create"},
+	}
+	got, err := es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %+v\ngot : %+v\n", want, got)
+	}
+
+	if err := es.syncPipelines(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.postCheckPipelines(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want no changes after sync, got: %+v", got)
+	}
+}
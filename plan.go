@@ -0,0 +1,301 @@
+package eskeeper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change is a planned change of Elasticsearch resource.
+type Change struct {
+	Resource string // index, alias, dataStream, indexTemplate, componentTemplate, ilmPolicy, pipeline, script, snapshotRepository or slmPolicy
+	Name     string
+	Action   string // create, update, recreate, open, close, reindex, delete, switch or rollover
+	Detail   string
+}
+
+func (c Change) String() string {
+	if c.Detail == "" {
+		return fmt.Sprintf("[%s] %s: %s", c.Action, c.Resource, c.Name)
+	}
+	return fmt.Sprintf("[%s] %s: %s (%s)", c.Action, c.Resource, c.Name, c.Detail)
+}
+
+func (c *esclient) planIndex(ctx context.Context, index index) ([]Change, error) {
+	changes := make([]Change, 0)
+	add := func(action, detail string) {
+		changes = append(changes, Change{Resource: "index", Name: index.Name, Action: action, Detail: detail})
+	}
+
+	ok, err := c.existIndex(ctx, index.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if index.State == "absent" {
+		if ok {
+			add("delete", "")
+		}
+		return changes, nil
+	}
+
+	// index dose not exist.
+	if !ok {
+		if index.From != nil {
+			add("create", fmt.Sprintf("%s from %s", index.From.Operation, index.From.Index))
+		} else {
+			add("create", index.Mapping)
+		}
+		if index.Lifecycle != nil {
+			add("update", fmt.Sprintf("lifecycle: %s", index.Lifecycle.Policy))
+		}
+		if index.Reindex.Source != "" {
+			add("reindex", fmt.Sprintf("%s -> %s", index.Reindex.Source, index.Name))
+		}
+		if index.Blocks != nil {
+			if bs := diffBlocks(map[string]interface{}{}, *index.Blocks); len(bs) > 0 {
+				add("update", fmt.Sprintf("blocks: %s", joinBlockChanges(bs)))
+			}
+		}
+		if index.Status == "close" {
+			add("close", "")
+		}
+		return changes, nil
+	}
+
+	// index already exists.
+	closed, err := c.staysClosed(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	// mappings & settings of closed index are not updated.
+	if hasIndexConfig(index) && !closed {
+		conf, err := readIndexMapping(index)
+		if err != nil {
+			return nil, err
+		}
+		if conf.Mappings != nil {
+			diffs, err := c.diffMappingsProperties(ctx, index, conf.Mappings)
+			if err != nil {
+				return nil, err
+			}
+			if len(diffs) > 0 {
+				add("update", fmt.Sprintf("mappings: %s", strings.Join(diffs, ", ")))
+			}
+		}
+		if conf.Settings != nil {
+			dynamic, static, err := c.diffSettingsWithLive(ctx, index, conf.Settings)
+			if err != nil {
+				return nil, err
+			}
+			if len(dynamic) > 0 {
+				add("update", fmt.Sprintf("settings: %s", strings.Join(sortedKeys(dynamic), ", ")))
+			}
+			if len(static) > 0 {
+				add("recreate", fmt.Sprintf("static settings need a new index: %s", strings.Join(static, ", ")))
+			}
+		}
+	}
+
+	if index.Lifecycle != nil {
+		dynamic, _, err := c.diffSettingsWithLive(ctx, index, lifecycleSettings(*index.Lifecycle))
+		if err != nil {
+			return nil, err
+		}
+		if len(dynamic) > 0 {
+			add("update", fmt.Sprintf("lifecycle: %s", index.Lifecycle.Policy))
+		}
+	}
+
+	if index.Blocks != nil {
+		bs, err := c.liveBlockChanges(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+		if len(bs) > 0 {
+			add("update", fmt.Sprintf("blocks: %s", joinBlockChanges(bs)))
+		}
+	}
+
+	if index.Reindex.Source != "" && index.Reindex.On == "always" {
+		add("reindex", fmt.Sprintf("%s -> %s", index.Reindex.Source, index.Name))
+	}
+
+	status, err := c.indexStatus(ctx, index.Name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case index.Status == "close" && status != "close":
+		add("close", "")
+	case index.Status != "close" && status == "close":
+		add("open", "")
+	}
+
+	return changes, nil
+}
+
+func (c *esclient) planAlias(ctx context.Context, alias alias) ([]Change, error) {
+	live, err := c.liveAlias(ctx, alias.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make([]string, 0, len(live))
+	for index := range live {
+		current = append(current, index)
+	}
+	sort.Strings(current)
+
+	if alias.State == "absent" {
+		if len(current) == 0 {
+			return []Change{}, nil
+		}
+		return []Change{
+			{Resource: "alias", Name: alias.Name, Action: "delete", Detail: strings.Join(current, ", ")},
+		}, nil
+	}
+
+	// indices of existing alias are managed by rollover.
+	if alias.Rollover != nil && len(current) > 0 {
+		return c.planRollover(ctx, alias)
+	}
+
+	want := make([]string, len(alias.Indices))
+	copy(want, alias.Indices)
+	sort.Strings(want)
+
+	if len(current) == 0 {
+		return []Change{
+			{Resource: "alias", Name: alias.Name, Action: "create", Detail: strings.Join(want, ", ")},
+		}, nil
+	}
+
+	if strings.Join(current, ",") == strings.Join(want, ",") {
+		diffs, err := diffAliasOptions(alias, live)
+		if err != nil {
+			return nil, err
+		}
+		if len(diffs) == 0 {
+			return []Change{}, nil
+		}
+		return []Change{
+			{Resource: "alias", Name: alias.Name, Action: "update", Detail: fmt.Sprintf("options: %s", strings.Join(diffs, ", "))},
+		}, nil
+	}
+
+	return []Change{
+		{
+			Resource: "alias",
+			Name:     alias.Name,
+			Action:   "switch",
+			Detail:   fmt.Sprintf("%s -> %s", strings.Join(current, ", "), strings.Join(want, ", ")),
+		},
+	}, nil
+}
+
+// plan lists changes that sync stage would apply without touching Elasticsearch.
+func (c *esclient) plan(ctx context.Context, conf config) ([]Change, error) {
+	changes := make([]Change, 0)
+
+	for _, r := range conf.SnapshotRepositories {
+		action, err := c.diffRepository(ctx, r)
+		if err != nil {
+			return nil, fmt.Errorf("plan snapshot repository %v: %w", r.Name, err)
+		}
+		if action != "" {
+			changes = append(changes, Change{Resource: "snapshotRepository", Name: r.Name, Action: action})
+		}
+	}
+
+	for _, p := range conf.SLMPolicies {
+		action, _, err := c.diffSLMPolicy(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("plan slm policy %v: %w", p.Name, err)
+		}
+		if action != "" {
+			changes = append(changes, Change{Resource: "slmPolicy", Name: p.Name, Action: action})
+		}
+	}
+
+	for _, p := range conf.ILMPolicies {
+		action, _, err := c.diffPolicy(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("plan ilm policy %v: %w", p.Name, err)
+		}
+		if action != "" {
+			changes = append(changes, Change{Resource: "ilmPolicy", Name: p.Name, Action: action})
+		}
+	}
+
+	for _, p := range conf.Pipelines {
+		action, _, err := c.diffPipeline(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("plan pipeline %v: %w", p.Name, err)
+		}
+		if action != "" {
+			changes = append(changes, Change{Resource: "pipeline", Name: p.Name, Action: action})
+		}
+	}
+
+	for _, s := range conf.Scripts {
+		action, _, err := c.diffScript(ctx, s)
+		if err != nil {
+			return nil, fmt.Errorf("plan script %v: %w", s.Name, err)
+		}
+		if action != "" {
+			changes = append(changes, Change{Resource: "script", Name: s.Name, Action: action})
+		}
+	}
+
+	for _, t := range conf.ComponentTemplates {
+		cs, err := c.planTemplate(ctx, componentTemplateKind, t)
+		if err != nil {
+			return nil, fmt.Errorf("plan component template %v: %w", t.Name, err)
+		}
+		changes = append(changes, cs...)
+	}
+
+	for _, t := range conf.IndexTemplates {
+		cs, err := c.planTemplate(ctx, indexTemplateKind, t)
+		if err != nil {
+			return nil, fmt.Errorf("plan index template %v: %w", t.Name, err)
+		}
+		changes = append(changes, cs...)
+	}
+
+	for _, d := range conf.DataStreams {
+		cs, err := c.planDataStream(ctx, d)
+		if err != nil {
+			return nil, fmt.Errorf("plan data stream %v: %w", d.Name, err)
+		}
+		changes = append(changes, cs...)
+	}
+
+	for _, index := range conf.Indices {
+		cs, err := c.planIndex(ctx, index)
+		if err != nil {
+			return nil, fmt.Errorf("plan index %v: %w", index.Name, err)
+		}
+		changes = append(changes, cs...)
+	}
+
+	for _, alias := range conf.Aliases {
+		cs, err := c.planAlias(ctx, alias)
+		if err != nil {
+			return nil, fmt.Errorf("plan alias %v: %w", alias.Name, err)
+		}
+		changes = append(changes, cs...)
+	}
+
+	undeclared, err := c.undeclaredAliases(ctx, conf)
+	if err != nil {
+		return nil, fmt.Errorf("plan prune aliases: %w", err)
+	}
+	for _, name := range undeclared {
+		changes = append(changes, Change{Resource: "alias", Name: name, Action: "delete", Detail: "undeclared"})
+	}
+
+	return changes, nil
+}
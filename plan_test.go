@@ -0,0 +1,121 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    config
+		setup   func(tb testing.TB)
+		want    []Change
+		cleanup func(tb testing.TB)
+	}{
+		{
+			name: "create",
+			conf: config{
+				Indices: []index{
+					{
+						Name:    "plan-create-v1",
+						Mapping: "testdata/test.json",
+						Status:  "close",
+					},
+				},
+				Aliases: []alias{
+					{
+						Name:    "plan-create-alias",
+						Indices: []string{"plan-create-v1"},
+					},
+				},
+			},
+			want: []Change{
+				{Resource: "index", Name: "plan-create-v1", Action: "create", Detail: "testdata/test.json"},
+				{Resource: "index", Name: "plan-create-v1", Action: "close"},
+				{Resource: "alias", Name: "plan-create-alias", Action: "create", Detail: "plan-create-v1"},
+			},
+		},
+		{
+			name: "no-changes",
+			conf: config{
+				Indices: []index{
+					{
+						Name: "plan-same-v1",
+					},
+				},
+				Aliases: []alias{
+					{
+						Name:    "plan-same-alias",
+						Indices: []string{"plan-same-v1"},
+					},
+				},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "plan-same-v1")
+				createTmpAliasHelper(tb, "plan-same-alias", "plan-same-v1")
+			},
+			want: []Change{},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"plan-same-v1"})
+			},
+		},
+		{
+			name: "open-and-switch",
+			conf: config{
+				Indices: []index{
+					{
+						Name: "plan-open-v1",
+					},
+					{
+						Name: "plan-open-v2",
+					},
+				},
+				Aliases: []alias{
+					{
+						Name:    "plan-switch-alias",
+						Indices: []string{"plan-open-v2"},
+					},
+				},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "plan-open-v1")
+				createTmpIndexHelper(tb, "plan-open-v2")
+				createTmpAliasHelper(tb, "plan-switch-alias", "plan-open-v1")
+				closeIndexHelper(tb, "plan-open-v1")
+			},
+			want: []Change{
+				{Resource: "index", Name: "plan-open-v1", Action: "open"},
+				{Resource: "alias", Name: "plan-switch-alias", Action: "switch", Detail: "plan-open-v1 -> plan-open-v2"},
+			},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"plan-open-v1", "plan-open-v2"})
+			},
+		},
+	}
+
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			got, err := es.plan(ctx, tt.conf)
+			if err != nil {
+				t.Error(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+			if tt.cleanup != nil {
+				tt.cleanup(t)
+			}
+		})
+	}
+}
@@ -1,27 +1,71 @@
 package eskeeper
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"strings"
 )
 
+type reindexBody struct {
+	Source    reindexSource `json:"source"`
+	Dest      reindexDest   `json:"dest"`
+	Script    *script       `json:"script,omitempty"`
+	MaxDocs   int           `json:"max_docs,omitempty"`
+	Conflicts string        `json:"conflicts,omitempty"`
+}
+
+type reindexSource struct {
+	Index  string                 `json:"index"`
+	Query  map[string]interface{} `json:"query,omitempty"`
+	Source *sourceFields          `json:"_source,omitempty"`
+	Size   int                    `json:"size,omitempty"`
+}
+
+type reindexDest struct {
+	Index    string `json:"index"`
+	Pipeline string `json:"pipeline,omitempty"`
+	OpType   string `json:"op_type,omitempty"`
+}
+
+func reindexQuery(dest string, reindex reindex) reindexBody {
+	return reindexBody{
+		Source: reindexSource{
+			Index:  reindex.Source,
+			Query:  reindex.Query,
+			Source: reindex.SourceFields,
+			Size:   reindex.Size,
+		},
+		Dest: reindexDest{
+			Index:    dest,
+			Pipeline: reindex.Pipeline,
+			OpType:   reindex.OpType,
+		},
+		Script:    reindex.Script,
+		MaxDocs:   reindex.MaxDocs,
+		Conflicts: reindex.Conflicts,
+	}
+}
+
+// reindex executes reindex into dest.
+// When waitForCompletion is false, the task ID is recorded in _meta of dest,
+// and next run waits for or reports the in-flight task instead of starting a second one.
 func (c *esclient) reindex(ctx context.Context, dest string, reindex reindex) error {
 	ri := c.client.Reindex
-	body := strings.NewReader(
-		fmt.Sprintf(`
-{
-  "source": {
-    "index": "%s"
-  },
-  "dest": {
-    "index": "%s"
-  }
-}`,
-			reindex.Source, dest,
-		),
-	)
+
+	running, err := c.checkInFlightReindex(ctx, dest, reindex.WaitForCompletion)
+	if err != nil {
+		return fmt.Errorf("check in-flight reindex: %w", err)
+	}
+	if running {
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(reindexQuery(dest, reindex)); err != nil {
+		return fmt.Errorf("build reindex query: %w", err)
+	}
 
 	slices := reindex.Slices
 	if slices == 0 {
@@ -29,7 +73,7 @@ func (c *esclient) reindex(ctx context.Context, dest string, reindex reindex) er
 	}
 
 	res, err := ri(
-		body,
+		&body,
 		ri.WithContext(ctx),
 		ri.WithSlices(slices),
 		ri.WithWaitForCompletion(reindex.WaitForCompletion),
@@ -44,5 +88,25 @@ func (c *esclient) reindex(ctx context.Context, dest string, reindex reindex) er
 		}
 		return fmt.Errorf("failed to reindex [index=%v, statusCode=%v, res=%v]", reindex.Source, res.StatusCode, string(body))
 	}
+
+	if reindex.WaitForCompletion {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("read reindex response: %w", err)
+	}
+	var task struct {
+		Task string `json:"task"`
+	}
+	if err := json.Unmarshal(b, &task); err != nil {
+		return fmt.Errorf("unmarshal reindex response: %w", err)
+	}
+	err = c.putTrackingMeta(ctx, dest, &trackingMeta{ReindexTask: task.Task, ReindexSource: reindex.Source})
+	if err != nil {
+		return fmt.Errorf("track reindex task: %w", err)
+	}
+	c.logf("[started] reindex task %v (%v -> %v)\n", task.Task, reindex.Source, dest)
 	return nil
 }
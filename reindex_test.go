@@ -2,6 +2,7 @@ package eskeeper
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 )
 
@@ -31,6 +32,36 @@ func TestReindex(t *testing.T) {
 				deleteIndexHelper(tb, []string{"reindex-src", "reindex-dest"})
 			},
 		},
+		{
+			name: "reindex-with-options",
+			dest: "reindex-options-dest",
+			reindex: reindex{
+				Source:            "reindex-options-src",
+				WaitForCompletion: true,
+				Query: map[string]interface{}{
+					"match": map[string]interface{}{"title": "title"},
+				},
+				SourceFields: &sourceFields{
+					Includes: []string{"title"},
+				},
+				Script: &script{
+					Source: "ctx._source.body = params.body",
+					Params: map[string]interface{}{"body": "replaced"},
+				},
+				OpType:    "create",
+				MaxDocs:   10,
+				Conflicts: "proceed",
+				Size:      100,
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "reindex-options-src")
+				createTmpIndexHelper(tb, "reindex-options-dest")
+				postDocHelper(tb, "reindex-options-src")
+			},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"reindex-options-src", "reindex-options-dest"})
+			},
+		},
 	}
 
 	es, err := newEsClient([]string{url}, "", "")
@@ -54,3 +85,58 @@ func TestReindex(t *testing.T) {
 		})
 	}
 }
+
+func TestReindexQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		dest    string
+		reindex reindex
+		want    string
+	}{
+		{
+			name: "simple",
+			dest: "test-v2",
+			reindex: reindex{
+				Source: "test-v1",
+			},
+			want: `{"source":{"index":"test-v1"},"dest":{"index":"test-v2"}}`,
+		},
+		{
+			name: "full",
+			dest: "test-v2",
+			reindex: reindex{
+				Source: "test-v1",
+				Query: map[string]interface{}{
+					"term": map[string]interface{}{"title": "a"},
+				},
+				SourceFields: &sourceFields{
+					Includes: []string{"title"},
+					Excludes: []string{"body"},
+				},
+				Script: &script{
+					Source: "ctx._source.id++",
+					Lang:   "painless",
+					Params: map[string]interface{}{"n": 1},
+				},
+				Pipeline:  "my-pipeline",
+				OpType:    "create",
+				MaxDocs:   1000,
+				Conflicts: "proceed",
+				Size:      500,
+			},
+			want: `{"source":{"index":"test-v1","query":{"term":{"title":"a"}},"_source":{"includes":["title"],"excludes":["body"]},"size":500},"dest":{"index":"test-v2","pipeline":"my-pipeline","op_type":"create"},"script":{"source":"ctx._source.id++","lang":"painless","params":{"n":1}},"max_docs":1000,"conflicts":"proceed"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(reindexQuery(tt.dest, tt.reindex))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("\nwant: %v\ngot : %v\n", tt.want, string(b))
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package eskeeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+const (
+	blocksWriteSetting    = "index.blocks.write"
+	requireNameSetting    = "index.routing.allocation.require._name"
+	numberOfShardsSetting = "index.number_of_shards"
+	replicasSetting       = "index.number_of_replicas"
+)
+
+type healthResponse struct {
+	Status   string `json:"status"`
+	TimedOut bool   `json:"timed_out"`
+}
+
+type catShardsResponse []struct {
+	Shard  string `json:"shard"`
+	Prirep string `json:"prirep"`
+	Node   string `json:"node"`
+}
+
+// targetShards returns number of shards of index created from source with operation.
+// Shrink creates single shard index by default.
+func targetShards(index index, sourceShards int) (int, error) {
+	if hasIndexConfig(index) {
+		conf, err := readIndexMapping(index)
+		if err != nil {
+			return 0, err
+		}
+		if v, ok := flattenSettings(conf.Settings)[numberOfShardsSetting]; ok {
+			n, err := strconv.Atoi(settingString(v))
+			if err != nil {
+				return 0, fmt.Errorf("invalid %v: %w", numberOfShardsSetting, err)
+			}
+			return n, nil
+		}
+	}
+	switch index.From.Operation {
+	case "shrink":
+		return 1, nil
+	case "clone":
+		return sourceShards, nil
+	}
+	return 0, fmt.Errorf("%v requires %v setting", index.From.Operation, numberOfShardsSetting)
+}
+
+// validateResizeShards checks number of shards of source and target for resize API.
+func validateResizeShards(operation string, source, target int) error {
+	if source <= 0 || target <= 0 {
+		return fmt.Errorf("number of shards must be positive [source=%v, target=%v]", source, target)
+	}
+	switch operation {
+	case "shrink":
+		if target >= source || source%target != 0 {
+			return fmt.Errorf("shrink target shards %v must be a factor of source shards %v", target, source)
+		}
+	case "split":
+		if target <= source || target%source != 0 {
+			return fmt.Errorf("split target shards %v must be a multiple of source shards %v", target, source)
+		}
+	case "clone":
+		if target != source {
+			return fmt.Errorf("clone target shards %v must be the same as source shards %v", target, source)
+		}
+	}
+	return nil
+}
+
+func (c *esclient) numberOfShards(ctx context.Context, index string) (int, error) {
+	row, err := c.catIndex(ctx, index, "pri")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(row["pri"])
+	if err != nil {
+		return 0, fmt.Errorf("parse number of shards of %v: %w", index, err)
+	}
+	return n, nil
+}
+
+// shrinkNode returns node of the first primary shard of the index.
+// All shards are relocated to the node before shrink.
+func (c *esclient) shrinkNode(ctx context.Context, index string) (string, error) {
+	cat := c.client.Cat.Shards
+	res, err := cat(
+		cat.WithIndex(index),
+		cat.WithContext(ctx),
+		cat.WithFormat("json"),
+		cat.WithH("shard", "prirep", "node"),
+	)
+	if err != nil {
+		return "", fmt.Errorf("cat shards: %w", err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("cat shards: %w", err)
+	}
+	if res.StatusCode != 200 {
+		return "", fmt.Errorf("failed to cat shards [index=%v, statusCode=%v, res=%v]", index, res.StatusCode, string(body))
+	}
+
+	var rows catShardsResponse
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return "", fmt.Errorf("unmarshal cat shards: %w", err)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Shard < rows[j].Shard })
+	for _, r := range rows {
+		if r.Prirep == "p" && r.Node != "" {
+			return r.Node, nil
+		}
+	}
+	return "", fmt.Errorf("no assigned primary shard of %v", index)
+}
+
+// waitForStatus waits until the index has the status and no shards are relocating.
+func (c *esclient) waitForStatus(ctx context.Context, index, status string) error {
+	health := c.client.Cluster.Health
+	res, err := health(
+		health.WithIndex(index),
+		health.WithContext(ctx),
+		health.WithWaitForStatus(status),
+		health.WithWaitForNoRelocatingShards(true),
+	)
+	if err != nil {
+		return fmt.Errorf("cluster health: %w", err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("cluster health: %w", err)
+	}
+
+	got := healthResponse{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return fmt.Errorf("unmarshal cluster health: %w", err)
+	}
+	if got.TimedOut {
+		return fmt.Errorf("timed out waiting for %v to be %v [status=%v]", index, status, got.Status)
+	}
+	return nil
+}
+
+func (c *esclient) putIndexSettings(ctx context.Context, index string, settings map[string]interface{}) error {
+	putSettings := c.client.Indices.PutSettings
+
+	j, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("marshal settings json: %w", err)
+	}
+	res, err := putSettings(
+		bytes.NewReader(j),
+		putSettings.WithIndex(index),
+		putSettings.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("update %v settings: %w", index, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("update %v settings: %w", index, err)
+		}
+		return fmt.Errorf("failed to update settings [index=%v, statusCode=%v, res=%v]", index, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// prepareResize sets write block, and relocates shards to one node for shrink, on source index.
+// Replicas are removed for shrink because they cannot be allocated with the primaries on the node.
+// It returns settings to restore source index after resize.
+func (c *esclient) prepareResize(ctx context.Context, ix index) (map[string]interface{}, error) {
+	source := ix.From.Index
+
+	temporary := map[string]interface{}{
+		blocksWriteSetting: true,
+	}
+	if ix.From.Operation == "shrink" {
+		node, err := c.shrinkNode(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		temporary[requireNameSetting] = node
+		temporary[replicasSetting] = 0
+	}
+
+	live, err := c.settings(ctx, index{Name: source})
+	if err != nil {
+		return nil, err
+	}
+	restore := make(map[string]interface{}, len(temporary))
+	for k := range temporary {
+		restore[k] = live[k] // nil removes the setting
+	}
+
+	err = c.putIndexSettings(ctx, source, temporary)
+	if err != nil {
+		return nil, err
+	}
+	return restore, nil
+}
+
+// resizeBody builds settings of target index.
+// Temporary settings copied from source are reverted to the values before resize.
+func resizeBody(index index, restore map[string]interface{}) (*bytes.Buffer, error) {
+	settings := make(map[string]interface{}, len(restore))
+	for k, v := range restore {
+		settings[k] = v
+	}
+	if hasIndexConfig(index) {
+		conf, err := readIndexMapping(index)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range flattenSettings(conf.Settings) {
+			settings[k] = v
+		}
+	}
+
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(map[string]interface{}{
+		"settings": settings,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("build resize body: %w", err)
+	}
+	return &buf, nil
+}
+
+func (c *esclient) resize(ctx context.Context, index index, restore map[string]interface{}) error {
+	i := c.client.Indices
+	source := index.From.Index
+
+	body, err := resizeBody(index, restore)
+	if err != nil {
+		return err
+	}
+
+	var res *esapi.Response
+	switch index.From.Operation {
+	case "shrink":
+		res, err = i.Shrink(source, index.Name, i.Shrink.WithBody(body), i.Shrink.WithContext(ctx))
+	case "split":
+		res, err = i.Split(source, index.Name, i.Split.WithBody(body), i.Split.WithContext(ctx))
+	case "clone":
+		res, err = i.Clone(source, index.Name, i.Clone.WithBody(body), i.Clone.WithContext(ctx))
+	default:
+		return fmt.Errorf("unsupported operation %v", index.From.Operation)
+	}
+	if err != nil {
+		return fmt.Errorf("%v index: %w", index.From.Operation, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to %v index [index=%v, statusCode=%v]", index.From.Operation, index.Name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to %v index [index=%v, statusCode=%v, res=%v]", index.From.Operation, index.Name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// createFrom creates index from source index with shrink, split or clone API.
+// Temporary settings on source index are restored even if resize fails.
+func (c *esclient) createFrom(ctx context.Context, index index) error {
+	source := index.From.Index
+	ok, err := c.existIndex(ctx, source)
+	if err != nil {
+		return fmt.Errorf("check index exists for %v process: %w", index.From.Operation, err)
+	}
+	if !ok {
+		return fmt.Errorf("%v (%s -> %s) conf is invalid. Make sure %s index exists", index.From.Operation, source, index.Name, source)
+	}
+
+	restore, err := c.prepareResize(ctx, index)
+	if err != nil {
+		return fmt.Errorf("prepare %v: %w", index.From.Operation, err)
+	}
+
+	err = c.waitForStatus(ctx, source, "green")
+	if err == nil {
+		err = c.resize(ctx, index, restore)
+	}
+	// replicas of target may not be allocated yet.
+	if err == nil {
+		err = c.waitForStatus(ctx, index.Name, "yellow")
+	}
+
+	rerr := c.putIndexSettings(ctx, source, restore)
+	if err != nil {
+		return fmt.Errorf("%v (%s -> %s): %w", index.From.Operation, source, index.Name, err)
+	}
+	if rerr != nil {
+		return fmt.Errorf("restore settings of %v: %w", source, rerr)
+	}
+	c.logf("[%v] index: %v -> %v\n", index.From.Operation, source, index.Name)
+	return nil
+}
+
+// preCheckFrom checks source index and number of shards instead of creating index with random name.
+func (c *esclient) preCheckFrom(ctx context.Context, ix index, createIndices map[string]struct{}) error {
+	source := ix.From.Index
+	ok, err := c.existIndex(ctx, source)
+	if err != nil {
+		return fmt.Errorf("pre-check: check index %v exists for %v: %w", source, ix.Name, err)
+	}
+	if !ok {
+		if _, ok := createIndices[source]; ok {
+			return nil
+		}
+		return fmt.Errorf("pre-check: source index %v for %v is not found", source, ix.Name)
+	}
+
+	sourceShards, err := c.numberOfShards(ctx, source)
+	if err != nil {
+		return fmt.Errorf("pre-check: %w", err)
+	}
+	shards, err := targetShards(ix, sourceShards)
+	if err != nil {
+		return fmt.Errorf("pre-check: %w", err)
+	}
+	if err := validateResizeShards(ix.From.Operation, sourceShards, shards); err != nil {
+		return fmt.Errorf("pre-check: %v: %w", ix.Name, err)
+	}
+	return nil
+}
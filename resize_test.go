@@ -0,0 +1,214 @@
+package eskeeper
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValidateResizeShards(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		source    int
+		target    int
+		wantErr   bool
+	}{
+		{name: "shrink", operation: "shrink", source: 4, target: 2},
+		{name: "shrink-not-factor", operation: "shrink", source: 4, target: 3, wantErr: true},
+		{name: "shrink-same", operation: "shrink", source: 2, target: 2, wantErr: true},
+		{name: "split", operation: "split", source: 2, target: 6},
+		{name: "split-not-multiple", operation: "split", source: 2, target: 3, wantErr: true},
+		{name: "clone", operation: "clone", source: 3, target: 3},
+		{name: "clone-different", operation: "clone", source: 3, target: 1, wantErr: true},
+		{name: "zero", operation: "clone", source: 0, target: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResizeShards(tt.operation, tt.source, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTargetShards(t *testing.T) {
+	tests := []struct {
+		name    string
+		index   index
+		source  int
+		want    int
+		wantErr bool
+	}{
+		{
+			name:   "mapping",
+			index:  index{Mapping: "testdata/resizeTarget.json", From: &from{Operation: "shrink"}},
+			source: 2,
+			want:   1,
+		},
+		{
+			name:   "shrink-default",
+			index:  index{From: &from{Operation: "shrink"}},
+			source: 4,
+			want:   1,
+		},
+		{
+			name:   "clone-default",
+			index:  index{From: &from{Operation: "clone"}},
+			source: 3,
+			want:   3,
+		},
+		{
+			name:    "split-without-shards",
+			index:   index{From: &from{Operation: "split"}},
+			source:  2,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := targetShards(tt.index, tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error: %v, got: %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("want: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSyncIndexFrom(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	err = es.syncIndex(ctx, index{Name: "resize-src", Mapping: "testdata/resizeSource.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteIndexHelper(t, []string{"resize-src", "resize-shrunk", "resize-clone"})
+	postDocHelper(t, "resize-src")
+
+	tests := []struct {
+		name   string
+		index  index
+		shards int
+	}{
+		{
+			name: "shrink",
+			index: index{
+				Name:    "resize-shrunk",
+				Mapping: "testdata/resizeTarget.json",
+				From:    &from{Index: "resize-src", Operation: "shrink"},
+			},
+			shards: 1,
+		},
+		{
+			name: "clone",
+			index: index{
+				Name: "resize-clone",
+				From: &from{Index: "resize-src", Operation: "clone"},
+			},
+			shards: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := es.preCheckFrom(ctx, tt.index, map[string]struct{}{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = es.syncIndex(ctx, tt.index)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			shards, err := es.numberOfShards(ctx, tt.index.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if shards != tt.shards {
+				t.Errorf("want shards: %v, got: %v", tt.shards, shards)
+			}
+
+			settings, err := es.settings(ctx, tt.index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := settings[requireNameSetting]; ok {
+				t.Errorf("%v is copied to target", requireNameSetting)
+			}
+
+			// write block of source is removed.
+			postDocHelper(t, "resize-src")
+		})
+	}
+}
+
+func TestSyncIndexShrinkReplicas(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// replicas of source cannot be allocated to the node that has all primaries.
+	err = es.syncIndex(ctx, index{Name: "resize-replica-src", Mapping: "testdata/resizeReplicaSource.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteIndexHelper(t, []string{"resize-replica-src", "resize-replica-shrunk"})
+	postDocHelper(t, "resize-replica-src")
+
+	target := index{
+		Name: "resize-replica-shrunk",
+		From: &from{Index: "resize-replica-src", Operation: "shrink"},
+	}
+	err = es.syncIndex(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"resize-replica-src", "resize-replica-shrunk"} {
+		settings, err := es.settings(ctx, index{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := settingString(settings[replicasSetting]); got != "1" {
+			t.Errorf("%v: want replicas: 1, got: %v", name, got)
+		}
+	}
+}
+
+func TestResizeBody(t *testing.T) {
+	restore := map[string]interface{}{
+		blocksWriteSetting: nil,
+		requireNameSetting: nil,
+		replicasSetting:    "1",
+	}
+	buf, err := resizeBody(index{Mapping: "testdata/resizeTarget.json"}, restore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]map[string]interface{}, 0)
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		blocksWriteSetting:    nil,
+		requireNameSetting:    nil,
+		replicasSetting:       float64(0), // declared in mapping file
+		numberOfShardsSetting: float64(1),
+	}
+	if !reflect.DeepEqual(got["settings"], want) {
+		t.Errorf("\nwant: %+v\ngot : %+v\n", want, got["settings"])
+	}
+}
@@ -0,0 +1,204 @@
+package eskeeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// rollover rolls the alias over to a new index when any of conditions is met.
+// The alias is created on the index of the alias as write index at first,
+// and after that the indices of the alias are managed by rollover.
+type rollover struct {
+	Mapping    string             `json:"mapping,omitempty"` // settings & mappings of new index
+	Conditions rolloverConditions `json:"conditions"`
+}
+
+// rolloverConditions is conditions of rollover API. fields are the same as the rollover API.
+type rolloverConditions struct {
+	MaxAge              string `json:"max_age,omitempty"`
+	MaxDocs             int64  `json:"max_docs,omitempty"`
+	MaxSize             string `json:"max_size,omitempty"`
+	MaxPrimaryShardSize string `json:"max_primary_shard_size,omitempty"` // Elasticsearch 7.12+
+}
+
+type rolloverResponse struct {
+	OldIndex   string          `json:"old_index"`
+	NewIndex   string          `json:"new_index"`
+	RolledOver bool            `json:"rolled_over"`
+	DryRun     bool            `json:"dry_run"`
+	Conditions map[string]bool `json:"conditions"`
+}
+
+// met returns sorted conditions that are met.
+func (r rolloverResponse) met() []string {
+	met := make([]string, 0, len(r.Conditions))
+	for cond, ok := range r.Conditions {
+		if ok {
+			met = append(met, cond)
+		}
+	}
+	sort.Strings(met)
+	return met
+}
+
+// rollover API generates new index name by incrementing the number suffix.
+var rolloverIndexPattern = regexp.MustCompile(`^.*-\d+$`)
+
+func validateRollover(alias alias) error {
+	r := alias.Rollover
+	if len(alias.Indices) != 1 {
+		return fmt.Errorf("%v alias with rollover must have exactly one index", alias.Name)
+	}
+	index := alias.Indices[0]
+	if !rolloverIndexPattern.MatchString(index) {
+		return fmt.Errorf("index %v of %v alias must match pattern '^.*-\\d+$' for rollover", index, alias.Name)
+	}
+	if opts, ok := alias.Options[index]; ok && opts.IsWriteIndex != nil && !*opts.IsWriteIndex {
+		return fmt.Errorf("index %v of %v alias must be write index for rollover", index, alias.Name)
+	}
+	if r.Conditions == (rolloverConditions{}) {
+		return fmt.Errorf("no rollover conditions in %v alias", alias.Name)
+	}
+	if r.Conditions.MaxDocs < 0 {
+		return errors.New("rollover max_docs must not be negative")
+	}
+	return nil
+}
+
+// bootstrapAlias returns alias pointing to the first index as write index,
+// so that rollover moves is_write_index to new index instead of the whole alias.
+func bootstrapAlias(a alias) alias {
+	index := a.Indices[0]
+	isWriteIndex := true
+
+	opts := make(map[string]aliasOptions, 1)
+	o := a.Options[index]
+	o.IsWriteIndex = &isWriteIndex
+	opts[index] = o
+
+	a.Options = opts
+	return a
+}
+
+func rolloverBody(r rollover) (*bytes.Buffer, error) {
+	body := map[string]interface{}{
+		"conditions": r.Conditions,
+	}
+	if r.Mapping != "" {
+		conf, err := readIndexConfig(r.Mapping)
+		if err != nil {
+			return nil, err
+		}
+		if conf.Settings != nil {
+			body["settings"] = conf.Settings
+		}
+		if conf.Mappings != nil {
+			body["mappings"] = conf.Mappings
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, fmt.Errorf("build rollover body: %w", err)
+	}
+	return &buf, nil
+}
+
+func (c *esclient) rollover(ctx context.Context, alias alias, dryRun bool) (rolloverResponse, error) {
+	body, err := rolloverBody(*alias.Rollover)
+	if err != nil {
+		return rolloverResponse{}, err
+	}
+
+	rollover := c.client.Indices.Rollover
+	res, err := rollover(
+		alias.Name,
+		rollover.WithBody(body),
+		rollover.WithDryRun(dryRun),
+		rollover.WithContext(ctx),
+	)
+	if err != nil {
+		return rolloverResponse{}, fmt.Errorf("rollover: %w", err)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return rolloverResponse{}, fmt.Errorf("rollover: %w", err)
+	}
+	if res.StatusCode != 200 {
+		return rolloverResponse{}, fmt.Errorf("failed to rollover [alias=%v, statusCode=%v, res=%v]", alias.Name, res.StatusCode, string(b))
+	}
+
+	got := rolloverResponse{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		return rolloverResponse{}, fmt.Errorf("unmarshal rollover json: %w", err)
+	}
+	return got, nil
+}
+
+// syncRollovers rolls over aliases whose conditions are met.
+// It runs after syncAliases so that the alias has been bootstrapped.
+func (c *esclient) syncRollovers(ctx context.Context, conf config) error {
+	for _, alias := range conf.Aliases {
+		if alias.Rollover == nil || alias.State == "absent" {
+			continue
+		}
+		got, err := c.rollover(ctx, alias, false)
+		if err != nil {
+			c.logf("[fail] alias: %v\n", alias.Name)
+			return fmt.Errorf("sync rollover: %w", err)
+		}
+		if !got.RolledOver {
+			c.logf("[skip] alias %v rollover conditions are not met\n", alias.Name)
+			continue
+		}
+		c.logf("[rollover] alias: %v (%v -> %v)\n", alias.Name, got.OldIndex, got.NewIndex)
+	}
+	return nil
+}
+
+// preCheckRollover checks mapping file of new index, and conditions with dry run when the alias exists.
+func (c *esclient) preCheckRollover(ctx context.Context, alias alias) (bool, error) {
+	if alias.Rollover.Mapping != "" {
+		if _, err := readIndexConfig(alias.Rollover.Mapping); err != nil {
+			return false, fmt.Errorf("pre-check: rollover mapping of alias %v: %w", alias.Name, err)
+		}
+	}
+	ok, err := c.existAlias(ctx, alias.Name)
+	if err != nil {
+		return false, fmt.Errorf("pre-check: check alias %v exists: %w", alias.Name, err)
+	}
+	if !ok {
+		return false, nil
+	}
+	if _, err := c.rollover(ctx, alias, true); err != nil {
+		return false, fmt.Errorf("pre-check: %w", err)
+	}
+	return true, nil
+}
+
+// planRollover evaluates rollover conditions of existing alias with dry run.
+func (c *esclient) planRollover(ctx context.Context, alias alias) ([]Change, error) {
+	got, err := c.rollover(ctx, alias, true)
+	if err != nil {
+		return nil, err
+	}
+	met := got.met()
+	if len(met) == 0 {
+		return []Change{}, nil
+	}
+	return []Change{
+		{
+			Resource: "alias",
+			Name:     alias.Name,
+			Action:   "rollover",
+			Detail:   fmt.Sprintf("%s -> %s, %s", got.OldIndex, got.NewIndex, strings.Join(met, ", ")),
+		},
+	}, nil
+}
@@ -0,0 +1,136 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestBootstrapAlias(t *testing.T) {
+	isWriteIndex := true
+
+	tests := []struct {
+		name  string
+		alias alias
+		want  alias
+	}{
+		{
+			name: "simple",
+			alias: alias{
+				Name:     "logs",
+				Indices:  []string{"logs-000001"},
+				Rollover: &rollover{Conditions: rolloverConditions{MaxDocs: 1}},
+			},
+			want: alias{
+				Name:    "logs",
+				Indices: []string{"logs-000001"},
+				Options: map[string]aliasOptions{
+					"logs-000001": {IsWriteIndex: &isWriteIndex},
+				},
+				Rollover: &rollover{Conditions: rolloverConditions{MaxDocs: 1}},
+			},
+		},
+		{
+			name: "keep-options",
+			alias: alias{
+				Name:    "logs",
+				Indices: []string{"logs-000001"},
+				Options: map[string]aliasOptions{
+					"logs-000001": {IndexRouting: "1"},
+				},
+				Rollover: &rollover{Conditions: rolloverConditions{MaxAge: "7d"}},
+			},
+			want: alias{
+				Name:    "logs",
+				Indices: []string{"logs-000001"},
+				Options: map[string]aliasOptions{
+					"logs-000001": {IndexRouting: "1", IsWriteIndex: &isWriteIndex},
+				},
+				Rollover: &rollover{Conditions: rolloverConditions{MaxAge: "7d"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bootstrapAlias(tt.alias)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want: %+v, got: %+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSyncRollovers(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	conf := config{
+		Aliases: []alias{
+			{
+				Name:    "rollover-logs",
+				Indices: []string{"rollover-logs-000001"},
+				Rollover: &rollover{
+					Mapping:    "testdata/test.json",
+					Conditions: rolloverConditions{MaxDocs: 1},
+				},
+			},
+		},
+	}
+
+	createTmpIndexHelper(t, "rollover-logs-000001")
+	defer deleteIndexHelper(t, []string{"rollover-logs-000001", "rollover-logs-000002"})
+
+	if err := es.syncAliases(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	// conditions are not met yet.
+	got, err := es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want no changes, got: %+v\n", got)
+	}
+
+	postDocHelper(t, "rollover-logs-000001")
+
+	want := []Change{
+		{
+			Resource: "alias",
+			Name:     "rollover-logs",
+			Action:   "rollover",
+			Detail:   "rollover-logs-000001 -> rollover-logs-000002, [max_docs: 1]",
+		},
+	}
+	got, err = es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %+v\ngot : %+v\n", want, got)
+	}
+
+	if err := es.syncRollovers(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	// second sync keeps rolled over alias.
+	if err := es.syncAliases(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	live, err := es.liveAlias(ctx, "rollover-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(live) != 2 {
+		t.Fatalf("want alias on 2 indices, got: %+v\n", live)
+	}
+	if boolValue(live["rollover-logs-000001"].IsWriteIndex) || !boolValue(live["rollover-logs-000002"].IsWriteIndex) {
+		t.Errorf("write index did not move: %+v\n", live)
+	}
+}
@@ -0,0 +1,277 @@
+package eskeeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/gofrs/uuid"
+)
+
+var scriptLang = map[string]struct{}{
+	"painless": struct{}{},
+	"mustache": struct{}{},
+	"":         struct{}{}, // default. painless
+}
+
+// storedScript is a stored script or search template.
+type storedScript struct {
+	Name    string         `json:"name"`
+	Lang    string         `json:"lang,omitempty"`
+	Source  string         `json:"source"`            // path to script source file
+	Execute *scriptExecute `json:"execute,omitempty"` // pre-check execution of painless script
+}
+
+// scriptExecute is request body options of painless execute API.
+type scriptExecute struct {
+	Context  string                 `json:"context,omitempty"` // painless_test (default), filter or score
+	Index    string                 `json:"index,omitempty"`
+	Document map[string]interface{} `json:"document,omitempty"`
+	Params   map[string]interface{} `json:"params,omitempty"`
+}
+
+func (s storedScript) lang() string {
+	if s.Lang == "" {
+		return "painless"
+	}
+	return s.Lang
+}
+
+func validateScript(s storedScript) error {
+	if s.Name == "" {
+		return errors.New("script name is empty")
+	}
+	_, ok := scriptLang[s.Lang]
+	if !ok {
+		return fmt.Errorf("unsupported script lang %v. [painless or mustache]", s.Lang)
+	}
+	if s.Source == "" {
+		return fmt.Errorf("source file of %v is empty", s.Name)
+	}
+	if _, err := ioutil.ReadFile(s.Source); err != nil {
+		return fmt.Errorf("read file %v: %w", s.Source, err)
+	}
+	if s.Execute != nil && s.lang() != "painless" {
+		return fmt.Errorf("execute of %v is supported for painless only", s.Name)
+	}
+	return nil
+}
+
+type liveScript struct {
+	Lang   string `json:"lang"`
+	Source string `json:"source"`
+}
+
+// liveScript returns stored script in Elasticsearch. nil means not found.
+func (c *esclient) liveScript(ctx context.Context, name string) (*liveScript, error) {
+	get := c.client.GetScript
+	res, err := get(name, get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get script %v: %w", name, err)
+	}
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get script %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get script [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+
+	got := struct {
+		Found  bool        `json:"found"`
+		Script *liveScript `json:"script"`
+	}{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal script json: %w", err)
+	}
+	if !got.Found {
+		return nil, nil
+	}
+	return got.Script, nil
+}
+
+func (c *esclient) putScript(ctx context.Context, name, lang, source string) error {
+	b, err := json.Marshal(map[string]interface{}{
+		"script": map[string]interface{}{
+			"lang":   lang,
+			"source": source,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal script json: %w", err)
+	}
+
+	put := c.client.PutScript
+	res, err := put(name, bytes.NewReader(b), put.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("put script %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to put script [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to put script [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (c *esclient) deleteScript(ctx context.Context, name string) error {
+	delete := c.client.DeleteScript
+	res, err := delete(name, delete.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("delete script: %w", err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to delete script [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to delete script [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// diffScript returns action of script change and source in the file. empty action means up to date.
+func (c *esclient) diffScript(ctx context.Context, s storedScript) (string, string, error) {
+	b, err := ioutil.ReadFile(s.Source)
+	if err != nil {
+		return "", "", fmt.Errorf("read file %v: %w", s.Source, err)
+	}
+	source := string(b)
+
+	live, err := c.liveScript(ctx, s.Name)
+	if err != nil {
+		return "", "", err
+	}
+	if live == nil {
+		return "create", source, nil
+	}
+	if live.Lang == s.lang() && live.Source == source {
+		return "", source, nil
+	}
+	return "update", source, nil
+}
+
+func (c *esclient) syncScripts(ctx context.Context, conf config) error {
+	for _, s := range conf.Scripts {
+		action, source, err := c.diffScript(ctx, s)
+		if err != nil {
+			c.logf("[fail] script: %v\n", s.Name)
+			return fmt.Errorf("sync script: %w", err)
+		}
+		if action != "" {
+			err = c.putScript(ctx, s.Name, s.lang(), source)
+			if err != nil {
+				c.logf("[fail] script: %v\n", s.Name)
+				return fmt.Errorf("sync script: %w", err)
+			}
+		}
+		c.logf("[synced] script: %v\n", s.Name)
+	}
+	return nil
+}
+
+// executePainless compiles and executes painless script with painless execute API.
+func (c *esclient) executePainless(ctx context.Context, name, source string, e *scriptExecute) error {
+	body := map[string]interface{}{
+		"script": map[string]interface{}{"source": source},
+	}
+	if e != nil {
+		if e.Params != nil {
+			body["script"] = map[string]interface{}{"source": source, "params": e.Params}
+		}
+		if e.Context != "" {
+			body["context"] = e.Context
+		}
+		if e.Index != "" || e.Document != nil {
+			body["context_setup"] = map[string]interface{}{
+				"index":    e.Index,
+				"document": e.Document,
+			}
+		}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal painless execute json: %w", err)
+	}
+
+	execute := c.client.ScriptsPainlessExecute
+	res, err := execute(execute.WithBody(bytes.NewReader(b)), execute.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("execute script %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to execute script [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to execute script [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (c *esclient) preCheckScript(ctx context.Context, s storedScript) error {
+	b, err := ioutil.ReadFile(s.Source)
+	if err != nil {
+		return fmt.Errorf("pre-check: read file %v: %w", s.Source, err)
+	}
+
+	if s.lang() == "painless" {
+		err = c.executePainless(ctx, s.Name, string(b), s.Execute)
+		if err != nil {
+			return fmt.Errorf("pre-check: %w", err)
+		}
+		return nil
+	}
+
+	// mustache template is compiled when it is stored.
+	u2, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("generate UUID for pre-check: %w", err)
+	}
+	name := fmt.Sprintf("eskeeper-%s", u2.String())
+	err = c.putScript(ctx, name, s.lang(), string(b))
+	if err != nil {
+		return fmt.Errorf("pre-check: pre create using random name script: %w", err)
+	}
+	err = c.deleteScript(ctx, name)
+	if err != nil {
+		return fmt.Errorf("pre-check: delete pre-created script: %w", err)
+	}
+	return nil
+}
+
+func (c *esclient) preCheckScripts(ctx context.Context, conf config) error {
+	for _, s := range conf.Scripts {
+		err := c.preCheckScript(ctx, s)
+		if err != nil {
+			c.logf("[fail] script: %v\n", s.Name)
+			return err
+		}
+		c.logf("[pass] script: %v\n", s.Name)
+	}
+	return nil
+}
+
+func (c *esclient) postCheckScripts(ctx context.Context, conf config) error {
+	for _, s := range conf.Scripts {
+		live, err := c.liveScript(ctx, s.Name)
+		if err != nil {
+			c.logf("[fail] script: %v\n", s.Name)
+			return fmt.Errorf("post-check: check script %v exist: %w", s.Name, err)
+		}
+		if live == nil {
+			c.logf("[fail] script: %v\n", s.Name)
+			return fmt.Errorf("post-check: script %v is not found", s.Name)
+		}
+		c.logf("[pass] script: %v\n", s.Name)
+	}
+	return nil
+}
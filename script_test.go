@@ -0,0 +1,94 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPreCheckScripts(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  storedScript
+		wantErr bool
+	}{
+		{
+			name: "painless",
+			script: storedScript{
+				Name:    "precheck-painless",
+				Source:  "testdata/script.painless",
+				Execute: &scriptExecute{Params: map[string]interface{}{"count": 1}},
+			},
+		},
+		{
+			name:    "invalid-painless",
+			script:  storedScript{Name: "precheck-invalid", Source: "testdata/invalid.painless"},
+			wantErr: true,
+		},
+		{
+			name:   "mustache",
+			script: storedScript{Name: "precheck-mustache", Lang: "mustache", Source: "testdata/searchTemplate.mustache"},
+		},
+	}
+
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := es.preCheckScripts(context.Background(), config{Scripts: []storedScript{tt.script}})
+			if tt.wantErr && err == nil {
+				t.Error("expect error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSyncScripts(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	conf := config{
+		Scripts: []storedScript{
+			{Name: "eskeeper-double", Source: "testdata/script.painless"},
+			{Name: "eskeeper-search", Lang: "mustache", Source: "testdata/searchTemplate.mustache"},
+		},
+	}
+	defer es.deleteScript(ctx, "eskeeper-double")
+	defer es.deleteScript(ctx, "eskeeper-search")
+
+	want := []Change{
+		{Resource: "script", Name: "eskeeper-double", Action: "create"},
+		{Resource: "script", Name: "eskeeper-search", Action: "create"},
+	}
+	got, err := es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %+v\ngot : %+v\n", want, got)
+	}
+
+	if err := es.syncScripts(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.postCheckScripts(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want no changes after sync, got: %+v", got)
+	}
+}
@@ -0,0 +1,437 @@
+package eskeeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotRepository is a snapshot repository. Settings are the same as the create repository API.
+type snapshotRepository struct {
+	Name     string                 `json:"name"`
+	Type     string                 `json:"type"`
+	Settings map[string]interface{} `json:"settings,omitempty"`
+}
+
+// slmPolicy is a snapshot lifecycle management policy.
+type slmPolicy struct {
+	Name   string `json:"name"`
+	Policy string `json:"policy"` // path to request body json
+}
+
+func validateSnapshotRepository(r snapshotRepository) error {
+	if r.Name == "" {
+		return errors.New("snapshot repository name is empty")
+	}
+	if r.Type == "" {
+		return fmt.Errorf("snapshot repository %v type is empty", r.Name)
+	}
+	return nil
+}
+
+func validateSLMPolicy(p slmPolicy) error {
+	if p.Name == "" {
+		return errors.New("slm policy name is empty")
+	}
+	if p.Policy == "" {
+		return fmt.Errorf("policy file of %v is empty", p.Name)
+	}
+	body, err := readJSON(p.Policy)
+	if err != nil {
+		return fmt.Errorf("policy json is invalid: %w", err)
+	}
+	if _, ok := body["repository"].(string); !ok {
+		return fmt.Errorf("policy json of %v has no repository field", p.Name)
+	}
+	return nil
+}
+
+// liveRepository returns snapshot repository in Elasticsearch. nil means not found.
+func (c *esclient) liveRepository(ctx context.Context, name string) (*snapshotRepository, error) {
+	get := c.client.Snapshot.GetRepository
+	res, err := get(get.WithRepository(name), get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get snapshot repository %v: %w", name, err)
+	}
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get snapshot repository %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get snapshot repository [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+
+	got := make(map[string]snapshotRepository, 0)
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshot repository json: %w", err)
+	}
+	r, ok := got[name]
+	if !ok {
+		return nil, nil
+	}
+	r.Name = name
+	return &r, nil
+}
+
+// equalRepository compares settings as string because Elasticsearch returns them as string.
+func equalRepository(live, want snapshotRepository) bool {
+	if live.Type != want.Type || len(live.Settings) != len(want.Settings) {
+		return false
+	}
+	for k, v := range want.Settings {
+		l, ok := live.Settings[k]
+		if !ok || settingString(l) != settingString(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *esclient) putRepository(ctx context.Context, r snapshotRepository) error {
+	b, err := json.Marshal(map[string]interface{}{
+		"type":     r.Type,
+		"settings": r.Settings,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal snapshot repository json: %w", err)
+	}
+
+	put := c.client.Snapshot.CreateRepository
+	res, err := put(r.Name, bytes.NewReader(b), put.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("put snapshot repository %v: %w", r.Name, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to put snapshot repository [name=%v, statusCode=%v]", r.Name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to put snapshot repository [name=%v, statusCode=%v, res=%v]", r.Name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// diffRepository returns action of snapshot repository change. empty action means up to date.
+func (c *esclient) diffRepository(ctx context.Context, r snapshotRepository) (string, error) {
+	live, err := c.liveRepository(ctx, r.Name)
+	if err != nil {
+		return "", err
+	}
+	if live == nil {
+		return "create", nil
+	}
+	if equalRepository(*live, r) {
+		return "", nil
+	}
+	return "update", nil
+}
+
+// liveSLMPolicy returns policy field of SLM policy in Elasticsearch. nil means not found.
+func (c *esclient) liveSLMPolicy(ctx context.Context, name string) (map[string]interface{}, error) {
+	get := c.client.SlmGetLifecycle
+	res, err := get(get.WithPolicyID(name), get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get slm policy %v: %w", name, err)
+	}
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get slm policy %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get slm policy [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+
+	got := make(map[string]struct {
+		Policy map[string]interface{} `json:"policy"`
+	}, 0)
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal slm policy json: %w", err)
+	}
+	p, ok := got[name]
+	if !ok {
+		return nil, nil
+	}
+	return p.Policy, nil
+}
+
+func (c *esclient) putSLMPolicy(ctx context.Context, name string, body map[string]interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal slm policy json: %w", err)
+	}
+
+	put := c.client.SlmPutLifecycle
+	res, err := put(name, put.WithBody(bytes.NewReader(b)), put.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("put slm policy %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to put slm policy [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to put slm policy [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// diffSLMPolicy returns action of SLM policy change. empty action means up to date.
+func (c *esclient) diffSLMPolicy(ctx context.Context, p slmPolicy) (string, map[string]interface{}, error) {
+	want, err := readJSON(p.Policy)
+	if err != nil {
+		return "", nil, err
+	}
+	live, err := c.liveSLMPolicy(ctx, p.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	if live == nil {
+		return "create", want, nil
+	}
+	l, err := normalizeJSON(live)
+	if err != nil {
+		return "", nil, fmt.Errorf("compare slm policy: %w", err)
+	}
+	w, err := normalizeJSON(want)
+	if err != nil {
+		return "", nil, fmt.Errorf("compare slm policy: %w", err)
+	}
+	if reflect.DeepEqual(l, w) {
+		return "", want, nil
+	}
+	return "update", want, nil
+}
+
+// syncSnapshots syncs snapshot repositories first because SLM policies refer to them.
+func (c *esclient) syncSnapshots(ctx context.Context, conf config) error {
+	for _, r := range conf.SnapshotRepositories {
+		action, err := c.diffRepository(ctx, r)
+		if err != nil {
+			c.logf("[fail] snapshotRepository: %v\n", r.Name)
+			return fmt.Errorf("sync snapshot repository: %w", err)
+		}
+		if action != "" {
+			err = c.putRepository(ctx, r)
+			if err != nil {
+				c.logf("[fail] snapshotRepository: %v\n", r.Name)
+				return fmt.Errorf("sync snapshot repository: %w", err)
+			}
+		}
+		c.logf("[synced] snapshotRepository: %v\n", r.Name)
+	}
+
+	for _, p := range conf.SLMPolicies {
+		action, body, err := c.diffSLMPolicy(ctx, p)
+		if err != nil {
+			c.logf("[fail] slmPolicy: %v\n", p.Name)
+			return fmt.Errorf("sync slm policy: %w", err)
+		}
+		if action != "" {
+			err = c.putSLMPolicy(ctx, p.Name, body)
+			if err != nil {
+				c.logf("[fail] slmPolicy: %v\n", p.Name)
+				return fmt.Errorf("sync slm policy: %w", err)
+			}
+		}
+		c.logf("[synced] slmPolicy: %v\n", p.Name)
+	}
+	return nil
+}
+
+// preCheckSLMPolicies checks repositories of SLM policies are declared or exist.
+func (c *esclient) preCheckSLMPolicies(ctx context.Context, conf config) error {
+	repositories := make(map[string]struct{}, len(conf.SnapshotRepositories))
+	for _, r := range conf.SnapshotRepositories {
+		repositories[r.Name] = struct{}{}
+	}
+
+	for _, p := range conf.SLMPolicies {
+		body, err := readJSON(p.Policy)
+		if err != nil {
+			c.logf("[fail] slmPolicy: %v\n", p.Name)
+			return fmt.Errorf("pre-check: %w", err)
+		}
+		repo, _ := body["repository"].(string)
+		if _, ok := repositories[repo]; !ok {
+			live, err := c.liveRepository(ctx, repo)
+			if err != nil {
+				c.logf("[fail] slmPolicy: %v\n", p.Name)
+				return fmt.Errorf("pre-check: check snapshot repository %v exists: %w", repo, err)
+			}
+			if live == nil {
+				c.logf("[fail] slmPolicy: %v\n", p.Name)
+				return fmt.Errorf("pre-check: snapshot repository %v for slm policy %v is not found", repo, p.Name)
+			}
+		}
+		c.logf("[pass] slmPolicy: %v\n", p.Name)
+	}
+	return nil
+}
+
+func (c *esclient) postCheckSnapshots(ctx context.Context, conf config) error {
+	for _, r := range conf.SnapshotRepositories {
+		live, err := c.liveRepository(ctx, r.Name)
+		if err != nil {
+			c.logf("[fail] snapshotRepository: %v\n", r.Name)
+			return fmt.Errorf("post-check: check snapshot repository %v exist: %w", r.Name, err)
+		}
+		if live == nil {
+			c.logf("[fail] snapshotRepository: %v\n", r.Name)
+			return fmt.Errorf("post-check: snapshot repository %v is not found", r.Name)
+		}
+		c.logf("[pass] snapshotRepository: %v\n", r.Name)
+	}
+	for _, p := range conf.SLMPolicies {
+		live, err := c.liveSLMPolicy(ctx, p.Name)
+		if err != nil {
+			c.logf("[fail] slmPolicy: %v\n", p.Name)
+			return fmt.Errorf("post-check: check slm policy %v exist: %w", p.Name, err)
+		}
+		if live == nil {
+			c.logf("[fail] slmPolicy: %v\n", p.Name)
+			return fmt.Errorf("post-check: slm policy %v is not found", p.Name)
+		}
+		c.logf("[pass] slmPolicy: %v\n", p.Name)
+	}
+	return nil
+}
+
+// mutatedIndices returns sorted names of existing indices that sync stage will change.
+// Closed indices are skipped because snapshot of closed index fails,
+// and closed indices to be deleted are refused because they would be deleted without backup.
+func (c *esclient) mutatedIndices(ctx context.Context, conf config) ([]string, error) {
+	indices := make([]string, 0)
+	for _, index := range conf.Indices {
+		changes, err := c.planIndex(ctx, index)
+		if err != nil {
+			return nil, fmt.Errorf("plan index %v: %w", index.Name, err)
+		}
+		// new index has nothing to snapshot.
+		if len(changes) == 0 || changes[0].Action == "create" {
+			continue
+		}
+		status, err := c.indexStatus(ctx, index.Name)
+		if err != nil {
+			return nil, err
+		}
+		if status == "close" {
+			if index.State == "absent" {
+				c.logf("[fail] snapshot index: %v\n", index.Name)
+				return nil, fmt.Errorf("index %v is closed and cannot be snapshotted before deletion. open it or delete it without snapshot", index.Name)
+			}
+			c.logf("[skip] snapshot index: %v (index is closed)\n", index.Name)
+			continue
+		}
+		indices = append(indices, index.Name)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// createDeclaredRepository creates snapshot repository declared in config.
+func (c *esclient) createDeclaredRepository(ctx context.Context, name string, conf config) error {
+	for _, r := range conf.SnapshotRepositories {
+		if r.Name != name {
+			continue
+		}
+		err := c.putRepository(ctx, r)
+		if err != nil {
+			c.logf("[fail] snapshotRepository: %v\n", r.Name)
+			return err
+		}
+		c.logf("[synced] snapshotRepository: %v\n", r.Name)
+		return nil
+	}
+	return fmt.Errorf("snapshot repository %v is not found", name)
+}
+
+func snapshotName(t time.Time) string {
+	return fmt.Sprintf("eskeeper-%s", strings.ToLower(t.UTC().Format("20060102t150405z")))
+}
+
+// createSnapshot takes snapshot of indices and waits for completion.
+func (c *esclient) createSnapshot(ctx context.Context, repository, name string, indices []string) error {
+	b, err := json.Marshal(map[string]interface{}{
+		"indices":              strings.Join(indices, ","),
+		"include_global_state": false,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal snapshot json: %w", err)
+	}
+
+	create := c.client.Snapshot.Create
+	res, err := create(
+		repository,
+		name,
+		create.WithBody(bytes.NewReader(b)),
+		create.WithWaitForCompletion(true),
+		create.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("create snapshot %v: %w", name, err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("create snapshot %v: %w", name, err)
+	}
+	if res.StatusCode != 200 {
+		return fmt.Errorf("failed to create snapshot [repository=%v, snapshot=%v, statusCode=%v, res=%v]", repository, name, res.StatusCode, string(body))
+	}
+
+	got := struct {
+		Snapshot struct {
+			State string `json:"state"`
+		} `json:"snapshot"`
+	}{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return fmt.Errorf("unmarshal snapshot json: %w", err)
+	}
+	if got.Snapshot.State != "SUCCESS" {
+		return fmt.Errorf("snapshot %v finished with %v state", name, got.Snapshot.State)
+	}
+	return nil
+}
+
+// snapshotBeforeSync takes snapshot of indices that sync stage will change.
+// Repository declared in config is created here when it does not exist yet.
+// It returns empty name when there is nothing to snapshot.
+func (c *esclient) snapshotBeforeSync(ctx context.Context, repository string, conf config) (string, error) {
+	live, err := c.liveRepository(ctx, repository)
+	if err != nil {
+		return "", fmt.Errorf("snapshot before sync: %w", err)
+	}
+	if live == nil {
+		err = c.createDeclaredRepository(ctx, repository, conf)
+		if err != nil {
+			return "", fmt.Errorf("snapshot before sync: %w", err)
+		}
+	}
+
+	indices, err := c.mutatedIndices(ctx, conf)
+	if err != nil {
+		return "", fmt.Errorf("snapshot before sync: %w", err)
+	}
+	if len(indices) == 0 {
+		return "", nil
+	}
+
+	name := snapshotName(time.Now())
+	err = c.createSnapshot(ctx, repository, name, indices)
+	if err != nil {
+		return "", fmt.Errorf("snapshot before sync: %w", err)
+	}
+	return name, nil
+}
@@ -0,0 +1,196 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEqualRepository(t *testing.T) {
+	tests := []struct {
+		name string
+		live snapshotRepository
+		want snapshotRepository
+		ok   bool
+	}{
+		{
+			name: "string-settings",
+			live: snapshotRepository{Type: "fs", Settings: map[string]interface{}{"location": "/tmp/backup", "compress": "true"}},
+			want: snapshotRepository{Type: "fs", Settings: map[string]interface{}{"location": "/tmp/backup", "compress": true}},
+			ok:   true,
+		},
+		{
+			name: "different-location",
+			live: snapshotRepository{Type: "fs", Settings: map[string]interface{}{"location": "/tmp/backup"}},
+			want: snapshotRepository{Type: "fs", Settings: map[string]interface{}{"location": "/tmp/other"}},
+			ok:   false,
+		},
+		{
+			name: "removed-setting",
+			live: snapshotRepository{Type: "fs", Settings: map[string]interface{}{"location": "/tmp/backup", "compress": "true"}},
+			want: snapshotRepository{Type: "fs", Settings: map[string]interface{}{"location": "/tmp/backup"}},
+			ok:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok := equalRepository(tt.live, tt.want); ok != tt.ok {
+				t.Errorf("want: %v, got: %v", tt.ok, ok)
+			}
+		})
+	}
+}
+
+func TestSnapshotName(t *testing.T) {
+	got := snapshotName(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	want := "eskeeper-20210304t050607z"
+	if got != want {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestSyncSnapshots(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	conf := config{
+		SnapshotRepositories: []snapshotRepository{
+			{Name: "eskeeper-backup", Type: "fs", Settings: map[string]interface{}{"location": "/tmp/eskeeper-backup"}},
+		},
+		SLMPolicies: []slmPolicy{
+			{Name: "eskeeper-nightly", Policy: "testdata/slmPolicy.json"},
+		},
+	}
+
+	want := []Change{
+		{Resource: "snapshotRepository", Name: "eskeeper-backup", Action: "create"},
+		{Resource: "slmPolicy", Name: "eskeeper-nightly", Action: "create"},
+	}
+	got, err := es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %+v\ngot : %+v\n", want, got)
+	}
+
+	if err := es.preCheckSLMPolicies(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.syncSnapshots(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	// slm policy refers to the repository. it is deleted first.
+	defer deleteRepositoryHelper(t, []string{"eskeeper-backup"})
+	defer deleteSLMPolicyHelper(t, "eskeeper-nightly")
+	if err := es.postCheckSnapshots(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want no changes after sync, got: %+v", got)
+	}
+}
+
+func TestSnapshotBeforeSync(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	repo := snapshotRepository{Name: "eskeeper-hook", Type: "fs", Settings: map[string]interface{}{"location": "/tmp/eskeeper-backup/hook"}}
+	if err := es.putRepository(ctx, repo); err != nil {
+		t.Fatal(err)
+	}
+	defer deleteRepositoryHelper(t, []string{repo.Name})
+
+	createTmpIndexHelper(t, "snapshot-v1")
+	createTmpIndexHelper(t, "snapshot-v0")
+	closeIndexHelper(t, "snapshot-v0")
+	defer deleteIndexHelper(t, []string{"snapshot-v0", "snapshot-v1"})
+
+	conf := config{
+		Indices: []index{
+			{Name: "snapshot-v0"},                  // mutated but closed
+			{Name: "snapshot-v1", Status: "close"}, // mutated
+			{Name: "snapshot-v2"},                  // created
+		},
+	}
+
+	indices, err := es.mutatedIndices(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(indices, []string{"snapshot-v1"}) {
+		t.Errorf("want: [snapshot-v1], got: %v", indices)
+	}
+
+	name, err := es.snapshotBeforeSync(ctx, repo.Name, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name == "" {
+		t.Error("expect snapshot name")
+	}
+
+	if _, err := es.snapshotBeforeSync(ctx, "not-found", conf); err == nil {
+		t.Error("expect error for unknown repository")
+	}
+
+	// closed index to be deleted cannot be snapshotted.
+	conf = config{
+		Indices: []index{
+			{Name: "snapshot-v0", State: "absent"},
+		},
+	}
+	if _, err := es.snapshotBeforeSync(ctx, repo.Name, conf); err == nil {
+		t.Error("expect error for closed index to be deleted")
+	}
+}
+
+func TestSnapshotBeforeSyncDeclaredRepository(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	createTmpIndexHelper(t, "snapshot-declared-v1")
+	defer deleteIndexHelper(t, []string{"snapshot-declared-v1"})
+
+	conf := config{
+		SnapshotRepositories: []snapshotRepository{
+			{Name: "eskeeper-declared", Type: "fs", Settings: map[string]interface{}{"location": "/tmp/eskeeper-backup/declared"}},
+		},
+		Indices: []index{
+			{Name: "snapshot-declared-v1", Status: "close"},
+		},
+	}
+
+	name, err := es.snapshotBeforeSync(ctx, "eskeeper-declared", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteRepositoryHelper(t, []string{"eskeeper-declared"})
+	if name == "" {
+		t.Error("expect snapshot name")
+	}
+
+	action, err := es.diffRepository(ctx, conf.SnapshotRepositories[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action != "" {
+		t.Errorf("want repository up to date, got: %v", action)
+	}
+}
@@ -0,0 +1,364 @@
+package eskeeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// trackingMetaKey is a key of mappings _meta where eskeeper records asynchronous reindex task.
+const trackingMetaKey = "eskeeper"
+
+var taskPollInterval = 5 * time.Second
+
+var errTaskNotFound = errors.New("task not found")
+
+// Task is an asynchronous reindex task tracked by eskeeper.
+type Task struct {
+	Index     string
+	ID        string
+	Source    string
+	Completed bool
+	Total     int64
+	Created   int64
+	Updated   int64
+	Deleted   int64
+	Error     string
+}
+
+func (t Task) String() string {
+	state := "running"
+	switch {
+	case t.Error != "":
+		state = "failed"
+	case t.Completed:
+		state = "completed"
+	}
+	done := t.Created + t.Updated + t.Deleted
+	s := fmt.Sprintf("[%s] index: %s (task=%s, %s -> %s, %d/%d docs)", state, t.Index, t.ID, t.Source, t.Index, done, t.Total)
+	if t.Error != "" {
+		s += ": " + t.Error
+	}
+	return s
+}
+
+type trackingMeta struct {
+	ReindexTask   string `json:"reindexTask,omitempty"`
+	ReindexSource string `json:"reindexSource,omitempty"`
+	Verify        string `json:"verify,omitempty"` // pending, failed or passed
+}
+
+type taskResponse struct {
+	Completed bool `json:"completed"`
+	Task      struct {
+		Status struct {
+			Total   int64 `json:"total"`
+			Created int64 `json:"created"`
+			Updated int64 `json:"updated"`
+			Deleted int64 `json:"deleted"`
+		} `json:"status"`
+	} `json:"task"`
+	Response struct {
+		Failures []interface{} `json:"failures"`
+	} `json:"response"`
+	Error map[string]interface{} `json:"error"`
+}
+
+// withoutTrackingMeta returns mappings without _meta recorded by eskeeper.
+func withoutTrackingMeta(mappings map[string]interface{}) map[string]interface{} {
+	meta, ok := mappings["_meta"].(map[string]interface{})
+	if !ok {
+		return mappings
+	}
+	if _, ok := meta[trackingMetaKey]; !ok {
+		return mappings
+	}
+
+	m := make(map[string]interface{}, len(mappings))
+	for k, v := range mappings {
+		m[k] = v
+	}
+	newMeta := make(map[string]interface{}, len(meta))
+	for k, v := range meta {
+		if k == trackingMetaKey {
+			continue
+		}
+		newMeta[k] = v
+	}
+	if len(newMeta) == 0 {
+		delete(m, "_meta")
+		return m
+	}
+	m["_meta"] = newMeta
+	return m
+}
+
+// withTrackingMeta returns mappings whose _meta keeps record of eskeeper in live _meta,
+// because put mapping API replaces whole _meta.
+func withTrackingMeta(mappings, liveMeta map[string]interface{}) map[string]interface{} {
+	meta, ok := mappings["_meta"].(map[string]interface{})
+	if !ok {
+		return mappings
+	}
+	tracking, ok := liveMeta[trackingMetaKey]
+	if !ok {
+		return mappings
+	}
+
+	m := make(map[string]interface{}, len(mappings))
+	for k, v := range mappings {
+		m[k] = v
+	}
+	newMeta := make(map[string]interface{}, len(meta)+1)
+	for k, v := range meta {
+		newMeta[k] = v
+	}
+	newMeta[trackingMetaKey] = tracking
+	m["_meta"] = newMeta
+	return m
+}
+
+func (c *esclient) liveMeta(ctx context.Context, name string) (map[string]interface{}, error) {
+	live, err := c.liveIndexConfig(ctx, index{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := live.Mappings["_meta"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}, nil
+	}
+	return meta, nil
+}
+
+// tracking returns record of eskeeper in _meta of the index.
+func (c *esclient) tracking(ctx context.Context, index string) (trackingMeta, error) {
+	t := trackingMeta{}
+	meta, err := c.liveMeta(ctx, index)
+	if err != nil {
+		return t, fmt.Errorf("get tracked task: %w", err)
+	}
+	v, ok := meta[trackingMetaKey]
+	if !ok {
+		return t, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return t, fmt.Errorf("marshal tracked task: %w", err)
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return t, fmt.Errorf("unmarshal tracked task: %w", err)
+	}
+	return t, nil
+}
+
+// trackedTask returns reindex task recorded in _meta of the index. nil means no task.
+func (c *esclient) trackedTask(ctx context.Context, index string) (*trackingMeta, error) {
+	t, err := c.tracking(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	if t.ReindexTask == "" {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+// clearTrackedTask removes reindex task from the record. verify result is kept.
+func (c *esclient) clearTrackedTask(ctx context.Context, index string) error {
+	t, err := c.tracking(ctx, index)
+	if err != nil {
+		return err
+	}
+	t.ReindexTask, t.ReindexSource = "", ""
+	return c.putTrackingMeta(ctx, index, &t)
+}
+
+// putTrackingMeta records t in _meta of the index. nil or empty t removes the record.
+func (c *esclient) putTrackingMeta(ctx context.Context, index string, t *trackingMeta) error {
+	putMapping := c.client.Indices.PutMapping
+
+	meta, err := c.liveMeta(ctx, index)
+	if err != nil {
+		return fmt.Errorf("get _meta: %w", err)
+	}
+	if t == nil || *t == (trackingMeta{}) {
+		delete(meta, trackingMetaKey)
+	} else {
+		meta[trackingMetaKey] = t
+	}
+
+	j, err := json.Marshal(map[string]interface{}{"_meta": meta})
+	if err != nil {
+		return fmt.Errorf("marshal _meta json: %w", err)
+	}
+
+	res, err := putMapping(
+		bytes.NewReader(j),
+		putMapping.WithIndex(index),
+		putMapping.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("update %v _meta: %w", index, err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("update %v _meta: %w", index, err)
+		}
+		return fmt.Errorf("update %v _meta: %v", index, string(body))
+	}
+	return nil
+}
+
+func (c *esclient) task(ctx context.Context, id string) (taskResponse, error) {
+	get := c.client.Tasks.Get
+	res, err := get(id, get.WithContext(ctx))
+	if err != nil {
+		return taskResponse{}, fmt.Errorf("get task: %w", err)
+	}
+	if res.StatusCode == 404 {
+		return taskResponse{}, errTaskNotFound
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return taskResponse{}, fmt.Errorf("get task: %w", err)
+	}
+	if res.StatusCode != 200 {
+		return taskResponse{}, fmt.Errorf("failed to get task [task=%v, statusCode=%v, res=%v]", id, res.StatusCode, string(body))
+	}
+
+	t := taskResponse{}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return taskResponse{}, fmt.Errorf("unmarshal task json: %w", err)
+	}
+	return t, nil
+}
+
+func (c *esclient) waitTask(ctx context.Context, id string) (taskResponse, error) {
+	for {
+		t, err := c.task(ctx, id)
+		if err != nil {
+			return t, err
+		}
+		if t.Completed {
+			return t, nil
+		}
+		c.logf("[wait] task %v: %v/%v docs\n", id, t.Task.Status.Created+t.Task.Status.Updated+t.Task.Status.Deleted, t.Task.Status.Total)
+
+		select {
+		case <-ctx.Done():
+			return t, ctx.Err()
+		case <-time.After(taskPollInterval):
+		}
+	}
+}
+
+func taskError(t taskResponse) string {
+	if len(t.Error) > 0 {
+		return fmt.Sprint(t.Error["reason"])
+	}
+	if len(t.Response.Failures) > 0 {
+		return fmt.Sprintf("%d failures: %v", len(t.Response.Failures), t.Response.Failures[0])
+	}
+	return ""
+}
+
+// checkInFlightReindex handles reindex task recorded by previous run.
+// It returns true when new reindex must not be started because the task is still running or has just finished.
+func (c *esclient) checkInFlightReindex(ctx context.Context, dest string, wait bool) (bool, error) {
+	tracked, err := c.trackedTask(ctx, dest)
+	if err != nil {
+		return false, err
+	}
+	if tracked == nil {
+		return false, nil
+	}
+
+	t, err := c.task(ctx, tracked.ReindexTask)
+	if errors.Is(err, errTaskNotFound) {
+		c.logf("[lost] reindex task %v (%v -> %v) is not found\n", tracked.ReindexTask, tracked.ReindexSource, dest)
+		return false, c.clearTrackedTask(ctx, dest)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !t.Completed {
+		if !wait {
+			c.logf("[running] reindex task %v (%v -> %v). skip starting new reindex\n", tracked.ReindexTask, tracked.ReindexSource, dest)
+			return true, nil
+		}
+		t, err = c.waitTask(ctx, tracked.ReindexTask)
+		if err != nil {
+			return false, err
+		}
+		err = c.clearTrackedTask(ctx, dest)
+		if err != nil {
+			return false, err
+		}
+		if msg := taskError(t); msg != "" {
+			return false, fmt.Errorf("reindex task %v failed: %v", tracked.ReindexTask, msg)
+		}
+		// in-flight task has finished the reindex of this run.
+		return true, nil
+	}
+
+	err = c.clearTrackedTask(ctx, dest)
+	if err != nil {
+		return false, err
+	}
+	if msg := taskError(t); msg != "" {
+		return false, fmt.Errorf("previous reindex task %v failed: %v", tracked.ReindexTask, msg)
+	}
+	c.logf("[completed] reindex task %v (%v -> %v)\n", tracked.ReindexTask, tracked.ReindexSource, dest)
+	return false, nil
+}
+
+// tasks returns reindex tasks tracked in declared indices.
+func (c *esclient) tasks(ctx context.Context, conf config) ([]Task, error) {
+	tasks := make([]Task, 0)
+	for _, index := range conf.Indices {
+		if index.State == "absent" || index.Reindex.Source == "" {
+			continue
+		}
+		ok, err := c.existIndex(ctx, index.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		tracked, err := c.trackedTask(ctx, index.Name)
+		if err != nil {
+			return nil, err
+		}
+		if tracked == nil {
+			continue
+		}
+
+		task := Task{Index: index.Name, ID: tracked.ReindexTask, Source: tracked.ReindexSource}
+		t, err := c.task(ctx, tracked.ReindexTask)
+		if errors.Is(err, errTaskNotFound) {
+			task.Error = "task not found"
+			tasks = append(tasks, task)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		task.Completed = t.Completed
+		task.Total = t.Task.Status.Total
+		task.Created = t.Task.Status.Created
+		task.Updated = t.Task.Status.Updated
+		task.Deleted = t.Task.Status.Deleted
+		task.Error = taskError(t)
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,180 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWithoutTrackingMeta(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name: "only-tracking",
+			mappings: map[string]interface{}{
+				"_meta": map[string]interface{}{
+					"eskeeper": map[string]interface{}{"reindexTask": "node:1"},
+				},
+				"properties": map[string]interface{}{},
+			},
+			want: map[string]interface{}{
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			name: "user-meta",
+			mappings: map[string]interface{}{
+				"_meta": map[string]interface{}{
+					"eskeeper": map[string]interface{}{"reindexTask": "node:1"},
+					"owner":    "search-team",
+				},
+			},
+			want: map[string]interface{}{
+				"_meta": map[string]interface{}{
+					"owner": "search-team",
+				},
+			},
+		},
+		{
+			name: "no-meta",
+			mappings: map[string]interface{}{
+				"properties": map[string]interface{}{},
+			},
+			want: map[string]interface{}{
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withoutTrackingMeta(tt.mappings)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithTrackingMeta(t *testing.T) {
+	tracking := map[string]interface{}{"reindexTask": "node:1"}
+	tests := []struct {
+		name     string
+		mappings map[string]interface{}
+		live     map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name: "user-meta",
+			mappings: map[string]interface{}{
+				"_meta": map[string]interface{}{"owner": "search-team"},
+			},
+			live: map[string]interface{}{"eskeeper": tracking, "owner": "old-team"},
+			want: map[string]interface{}{
+				"_meta": map[string]interface{}{"owner": "search-team", "eskeeper": tracking},
+			},
+		},
+		{
+			name: "no-tracking",
+			mappings: map[string]interface{}{
+				"_meta": map[string]interface{}{"owner": "search-team"},
+			},
+			live: map[string]interface{}{},
+			want: map[string]interface{}{
+				"_meta": map[string]interface{}{"owner": "search-team"},
+			},
+		},
+		{
+			name: "no-meta",
+			mappings: map[string]interface{}{
+				"properties": map[string]interface{}{},
+			},
+			live: map[string]interface{}{"eskeeper": tracking},
+			want: map[string]interface{}{
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withTrackingMeta(tt.mappings, tt.live)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\nwant: %+v\ngot : %+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTasks(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    config
+		setup   func(tb testing.TB)
+		cleanup func(tb testing.TB)
+	}{
+		{
+			name: "async-reindex",
+			conf: config{
+				Indices: []index{
+					{
+						Name:    "tasks-dest",
+						Mapping: "testdata/test.json",
+						Reindex: reindex{
+							Source:            "tasks-src",
+							WaitForCompletion: false,
+							On:                "always",
+						},
+					},
+				},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "tasks-src")
+				createTmpIndexHelper(tb, "tasks-dest")
+				postDocHelper(tb, "tasks-src")
+			},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"tasks-src", "tasks-dest"})
+			},
+		},
+	}
+
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+
+			ix := tt.conf.Indices[0]
+			err := es.reindex(ctx, ix.Name, ix.Reindex)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tasks, err := es.tasks(ctx, tt.conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != 1 {
+				t.Fatalf("want 1 tracked task, got: %+v\n", tasks)
+			}
+
+			if tasks[0].Index != ix.Name || tasks[0].Source != ix.Reindex.Source {
+				t.Errorf("unexpected task: %+v\n", tasks[0])
+			}
+
+			if tt.cleanup != nil {
+				tt.cleanup(t)
+			}
+		})
+	}
+}
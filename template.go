@@ -0,0 +1,408 @@
+package eskeeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/gofrs/uuid"
+)
+
+// template is a composable index template or component template.
+type template struct {
+	Name     string `json:"name"`
+	Template string `json:"template"` // path to request body json
+}
+
+const (
+	indexTemplateKind     = "indexTemplate"
+	componentTemplateKind = "componentTemplate"
+)
+
+type indexTemplatesResponse struct {
+	IndexTemplates []struct {
+		Name          string                 `json:"name"`
+		IndexTemplate map[string]interface{} `json:"index_template"`
+	} `json:"index_templates"`
+}
+
+type componentTemplatesResponse struct {
+	ComponentTemplates []struct {
+		Name              string                 `json:"name"`
+		ComponentTemplate map[string]interface{} `json:"component_template"`
+	} `json:"component_templates"`
+}
+
+func readJSON(path string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file %v: %w", path, err)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("unmarshal %v: %w", path, err)
+	}
+	return v, nil
+}
+
+func validateTemplate(t template) error {
+	if t.Name == "" {
+		return errors.New("template name is empty")
+	}
+	if t.Template == "" {
+		return fmt.Errorf("template file of %v is empty", t.Name)
+	}
+	_, err := readJSON(t.Template)
+	if err != nil {
+		return fmt.Errorf("template json is invalid: %w", err)
+	}
+	return nil
+}
+
+// normalizeTemplate flattens settings because Elasticsearch returns them as nested string values.
+func normalizeTemplate(body map[string]interface{}) (interface{}, error) {
+	n := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		n[k] = v
+	}
+	if t, ok := body["template"].(map[string]interface{}); ok {
+		nt := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			nt[k] = v
+		}
+		if settings, ok := t["settings"].(map[string]interface{}); ok {
+			flat := make(map[string]interface{}, 0)
+			for k, v := range flattenSettings(settings) {
+				flat[k] = settingString(v)
+			}
+			nt["settings"] = flat
+		}
+		n["template"] = nt
+	}
+	return normalizeJSON(n)
+}
+
+func equalTemplate(live, want map[string]interface{}) (bool, error) {
+	l, err := normalizeTemplate(live)
+	if err != nil {
+		return false, err
+	}
+	w, err := normalizeTemplate(want)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(l, w), nil
+}
+
+// liveTemplate returns template body in Elasticsearch. nil means not found.
+func (c *esclient) liveTemplate(ctx context.Context, kind, name string) (map[string]interface{}, error) {
+	var body []byte
+	var statusCode int
+
+	switch kind {
+	case indexTemplateKind:
+		get := c.client.Indices.GetIndexTemplate
+		res, err := get(get.WithName(name), get.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("get %v %v: %w", kind, name, err)
+		}
+		statusCode = res.StatusCode
+		body, err = ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("get %v %v: %w", kind, name, err)
+		}
+	case componentTemplateKind:
+		get := c.client.Cluster.GetComponentTemplate
+		res, err := get(get.WithName(name), get.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("get %v %v: %w", kind, name, err)
+		}
+		statusCode = res.StatusCode
+		body, err = ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("get %v %v: %w", kind, name, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported template kind %v", kind)
+	}
+
+	if statusCode == 404 {
+		return nil, nil
+	}
+	if statusCode != 200 {
+		return nil, fmt.Errorf("failed to get %v [name=%v, statusCode=%v, res=%v]", kind, name, statusCode, string(body))
+	}
+
+	if kind == indexTemplateKind {
+		got := indexTemplatesResponse{}
+		if err := json.Unmarshal(body, &got); err != nil {
+			return nil, fmt.Errorf("unmarshal %v json: %w", kind, err)
+		}
+		for _, t := range got.IndexTemplates {
+			if t.Name == name {
+				return t.IndexTemplate, nil
+			}
+		}
+		return nil, nil
+	}
+
+	got := componentTemplatesResponse{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal %v json: %w", kind, err)
+	}
+	for _, t := range got.ComponentTemplates {
+		if t.Name == name {
+			return t.ComponentTemplate, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *esclient) putTemplate(ctx context.Context, kind, name string, body map[string]interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal %v json: %w", kind, err)
+	}
+
+	var statusCode int
+	var resBody []byte
+	switch kind {
+	case indexTemplateKind:
+		put := c.client.Indices.PutIndexTemplate
+		res, err := put(name, bytes.NewReader(b), put.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("put %v %v: %w", kind, name, err)
+		}
+		statusCode = res.StatusCode
+		resBody, _ = ioutil.ReadAll(res.Body)
+	case componentTemplateKind:
+		put := c.client.Cluster.PutComponentTemplate
+		res, err := put(name, bytes.NewReader(b), put.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("put %v %v: %w", kind, name, err)
+		}
+		statusCode = res.StatusCode
+		resBody, _ = ioutil.ReadAll(res.Body)
+	default:
+		return fmt.Errorf("unsupported template kind %v", kind)
+	}
+
+	if statusCode != 200 {
+		return fmt.Errorf("failed to put %v [name=%v, statusCode=%v, res=%v]", kind, name, statusCode, string(resBody))
+	}
+	return nil
+}
+
+func (c *esclient) deleteComponentTemplate(ctx context.Context, name string) error {
+	delete := c.client.Cluster.DeleteComponentTemplate
+	res, err := delete(name, delete.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("delete component template: %w", err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to delete component template [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to delete component template [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// simulateIndexTemplate validates index template body as if it replaces the template of the name.
+func (c *esclient) simulateIndexTemplate(ctx context.Context, name string, body map[string]interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal index template json: %w", err)
+	}
+
+	simulate := c.client.Indices.SimulateTemplate
+	res, err := simulate(
+		simulate.WithName(name),
+		simulate.WithBody(bytes.NewReader(b)),
+		simulate.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("simulate index template: %w", err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to simulate index template [name=%v, statusCode=%v]", name, res.StatusCode)
+		}
+		return fmt.Errorf("failed to simulate index template [name=%v, statusCode=%v, res=%v]", name, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// syncTemplate creates or updates template. It does nothing when the template is up to date.
+func (c *esclient) syncTemplate(ctx context.Context, kind string, t template) error {
+	want, err := readJSON(t.Template)
+	if err != nil {
+		return err
+	}
+	live, err := c.liveTemplate(ctx, kind, t.Name)
+	if err != nil {
+		return err
+	}
+	if live != nil {
+		ok, err := equalTemplate(live, want)
+		if err != nil {
+			return fmt.Errorf("compare %v: %w", kind, err)
+		}
+		if ok {
+			return nil
+		}
+	}
+	return c.putTemplate(ctx, kind, t.Name, want)
+}
+
+// syncTemplates syncs component templates first because index templates are composed of them.
+func (c *esclient) syncTemplates(ctx context.Context, conf config) error {
+	for _, t := range conf.ComponentTemplates {
+		err := c.syncTemplate(ctx, componentTemplateKind, t)
+		if err != nil {
+			c.logf("[fail] componentTemplate: %v\n", t.Name)
+			return fmt.Errorf("sync component template: %w", err)
+		}
+		c.logf("[synced] componentTemplate: %v\n", t.Name)
+	}
+	for _, t := range conf.IndexTemplates {
+		err := c.syncTemplate(ctx, indexTemplateKind, t)
+		if err != nil {
+			c.logf("[fail] indexTemplate: %v\n", t.Name)
+			return fmt.Errorf("sync index template: %w", err)
+		}
+		c.logf("[synced] indexTemplate: %v\n", t.Name)
+	}
+	return nil
+}
+
+func (c *esclient) preCheckComponentTemplate(ctx context.Context, t template) error {
+	u2, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("generate UUID for pre-check: %w", err)
+	}
+	name := fmt.Sprintf("eskeeper-%s", u2.String())
+
+	body, err := readJSON(t.Template)
+	if err != nil {
+		return fmt.Errorf("pre-check: %w", err)
+	}
+	err = c.putTemplate(ctx, componentTemplateKind, name, body)
+	if err != nil {
+		return fmt.Errorf("pre-check: pre create using random name component template: %w", err)
+	}
+	err = c.deleteComponentTemplate(ctx, name)
+	if err != nil {
+		return fmt.Errorf("pre-check: delete pre-created component template: %w", err)
+	}
+	return nil
+}
+
+// preCheckIndexTemplate checks composed component templates exist and simulates the template.
+// Simulation is skipped when the template is composed of component templates created in this sync.
+func (c *esclient) preCheckIndexTemplate(ctx context.Context, t template, components map[string]struct{}) error {
+	body, err := readJSON(t.Template)
+	if err != nil {
+		return fmt.Errorf("pre-check: %w", err)
+	}
+
+	composedOf, _ := body["composed_of"].([]interface{})
+	simulate := true
+	for _, v := range composedOf {
+		name := fmt.Sprint(v)
+		live, err := c.liveTemplate(ctx, componentTemplateKind, name)
+		if err != nil {
+			return fmt.Errorf("pre-check: check component template %v exists: %w", name, err)
+		}
+		if live != nil {
+			continue
+		}
+		if _, ok := components[name]; !ok {
+			return fmt.Errorf("pre-check: component template %v for index template %v is not found", name, t.Name)
+		}
+		simulate = false
+	}
+	if !simulate {
+		c.logf("[skip] simulate indexTemplate %v composed of new component templates\n", t.Name)
+		return nil
+	}
+
+	err = c.simulateIndexTemplate(ctx, t.Name, body)
+	if err != nil {
+		return fmt.Errorf("pre-check: %w", err)
+	}
+	return nil
+}
+
+func (c *esclient) preCheckTemplates(ctx context.Context, conf config) error {
+	components := make(map[string]struct{}, len(conf.ComponentTemplates))
+	for _, t := range conf.ComponentTemplates {
+		components[t.Name] = struct{}{}
+		err := c.preCheckComponentTemplate(ctx, t)
+		if err != nil {
+			c.logf("[fail] componentTemplate: %v\n", t.Name)
+			return err
+		}
+		c.logf("[pass] componentTemplate: %v\n", t.Name)
+	}
+	for _, t := range conf.IndexTemplates {
+		err := c.preCheckIndexTemplate(ctx, t, components)
+		if err != nil {
+			c.logf("[fail] indexTemplate: %v\n", t.Name)
+			return err
+		}
+		c.logf("[pass] indexTemplate: %v\n", t.Name)
+	}
+	return nil
+}
+
+func (c *esclient) postCheckTemplates(ctx context.Context, conf config) error {
+	check := func(kind string, templates []template) error {
+		for _, t := range templates {
+			live, err := c.liveTemplate(ctx, kind, t.Name)
+			if err != nil {
+				c.logf("[fail] %v: %v\n", kind, t.Name)
+				return fmt.Errorf("post-check: check %v %v exist: %w", kind, t.Name, err)
+			}
+			if live == nil {
+				c.logf("[fail] %v: %v\n", kind, t.Name)
+				return fmt.Errorf("post-check: %v %v is not found", kind, t.Name)
+			}
+			c.logf("[pass] %v: %v\n", kind, t.Name)
+		}
+		return nil
+	}
+	err := check(componentTemplateKind, conf.ComponentTemplates)
+	if err != nil {
+		return err
+	}
+	return check(indexTemplateKind, conf.IndexTemplates)
+}
+
+func (c *esclient) planTemplate(ctx context.Context, kind string, t template) ([]Change, error) {
+	want, err := readJSON(t.Template)
+	if err != nil {
+		return nil, err
+	}
+	live, err := c.liveTemplate(ctx, kind, t.Name)
+	if err != nil {
+		return nil, err
+	}
+	if live == nil {
+		return []Change{{Resource: kind, Name: t.Name, Action: "create"}}, nil
+	}
+	ok, err := equalTemplate(live, want)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return []Change{}, nil
+	}
+	return []Change{{Resource: kind, Name: t.Name, Action: "update"}}, nil
+}
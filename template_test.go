@@ -0,0 +1,116 @@
+package eskeeper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestEqualTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		live map[string]interface{}
+		want map[string]interface{}
+		ok   bool
+	}{
+		{
+			name: "same",
+			live: map[string]interface{}{"index_patterns": []interface{}{"logs-*"}, "priority": float64(1)},
+			want: map[string]interface{}{"index_patterns": []interface{}{"logs-*"}, "priority": float64(1)},
+			ok:   true,
+		},
+		{
+			name: "settings-normalized",
+			live: map[string]interface{}{
+				"template": map[string]interface{}{
+					"settings": map[string]interface{}{
+						"index": map[string]interface{}{"number_of_shards": "1"},
+					},
+				},
+			},
+			want: map[string]interface{}{
+				"template": map[string]interface{}{
+					"settings": map[string]interface{}{"number_of_shards": float64(1)},
+				},
+			},
+			ok: true,
+		},
+		{
+			name: "different",
+			live: map[string]interface{}{"index_patterns": []interface{}{"logs-*"}},
+			want: map[string]interface{}{"index_patterns": []interface{}{"metrics-*"}},
+			ok:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := equalTemplate(tt.live, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok {
+				t.Errorf("want: %v, got: %v", tt.ok, ok)
+			}
+		})
+	}
+}
+
+func TestSyncTemplates(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	conf := config{
+		ComponentTemplates: []template{
+			{Name: "eskeeper-base", Template: "testdata/componentTemplate.json"},
+		},
+		IndexTemplates: []template{
+			{Name: "eskeeper-logs", Template: "testdata/indexTemplate.json"},
+		},
+	}
+
+	want := []Change{
+		{Resource: "componentTemplate", Name: "eskeeper-base", Action: "create"},
+		{Resource: "indexTemplate", Name: "eskeeper-logs", Action: "create"},
+	}
+	got, err := es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %+v\ngot : %+v\n", want, got)
+	}
+
+	if err := es.preCheckTemplates(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.syncTemplates(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.postCheckTemplates(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	// simulation runs against created component template.
+	if err := es.preCheckTemplates(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = es.plan(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want no changes after sync, got: %+v", got)
+	}
+
+	del := es.client.Indices.DeleteIndexTemplate
+	if _, err := del("eskeeper-logs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.deleteComponentTemplate(ctx, "eskeeper-base"); err != nil {
+		t.Fatal(err)
+	}
+}
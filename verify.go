@@ -0,0 +1,270 @@
+package eskeeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+func (c *esclient) refresh(ctx context.Context, index string) error {
+	refresh := c.client.Indices.Refresh
+	res, err := refresh(
+		refresh.WithIndex(index),
+		refresh.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("refresh index: %w", err)
+	}
+	if res.StatusCode != 200 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to refresh index [index=%v, statusCode=%v]", index, res.StatusCode)
+		}
+		return fmt.Errorf("failed to refresh index [index=%v, statusCode=%v, res=%v]", index, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (c *esclient) count(ctx context.Context, index string, query map[string]interface{}) (int64, error) {
+	count := c.client.Count
+
+	opts := []func(*esapi.CountRequest){
+		count.WithIndex(index),
+		count.WithContext(ctx),
+	}
+	if query != nil {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"query": query}); err != nil {
+			return 0, fmt.Errorf("build count query: %w", err)
+		}
+		opts = append(opts, count.WithBody(&buf))
+	}
+
+	res, err := count(opts...)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+	if res.StatusCode != 200 {
+		return 0, fmt.Errorf("failed to count [index=%v, statusCode=%v, res=%v]", index, res.StatusCode, string(body))
+	}
+
+	var got struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return 0, fmt.Errorf("unmarshal count json: %w", err)
+	}
+	return got.Count, nil
+}
+
+// sampleIDs returns document IDs randomly sampled from the index.
+func (c *esclient) sampleIDs(ctx context.Context, index string, query map[string]interface{}, size int) ([]string, error) {
+	search := c.client.Search
+
+	if query == nil {
+		query = map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+	q := map[string]interface{}{
+		"size":    size,
+		"_source": false,
+		"query": map[string]interface{}{
+			"function_score": map[string]interface{}{
+				"query":        query,
+				"random_score": map[string]interface{}{},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(q); err != nil {
+		return nil, fmt.Errorf("build sample query: %w", err)
+	}
+
+	res, err := search(
+		search.WithIndex(index),
+		search.WithContext(ctx),
+		search.WithBody(&buf),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sample ids: %w", err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("sample ids: %w", err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to sample ids [index=%v, statusCode=%v, res=%v]", index, res.StatusCode, string(body))
+	}
+
+	var got struct {
+		Hits struct {
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return nil, fmt.Errorf("unmarshal search json: %w", err)
+	}
+
+	ids := make([]string, 0, len(got.Hits.Hits))
+	for _, h := range got.Hits.Hits {
+		ids = append(ids, h.ID)
+	}
+	return ids, nil
+}
+
+// verify results recorded in _meta of dest.
+const (
+	verifyPending = "pending"
+	verifyFailed  = "failed"
+	verifyPassed  = "passed"
+)
+
+// withinTolerance reports whether got is close enough to want.
+// Empty dest never passes when source has documents.
+func withinTolerance(want, got int64, tolerance float64) bool {
+	if want > 0 && got == 0 {
+		return false
+	}
+	diff := math.Abs(float64(want - got))
+	return diff <= tolerance*float64(want)
+}
+
+// verifyReindex compares source and dest after reindex.
+func (c *esclient) verifyReindex(ctx context.Context, dest string, reindex reindex) error {
+	v := reindex.Verify
+	if v == nil {
+		return nil
+	}
+
+	err := c.refresh(ctx, dest)
+	if err != nil {
+		return err
+	}
+
+	want, err := c.count(ctx, reindex.Source, reindex.Query)
+	if err != nil {
+		return err
+	}
+	if reindex.MaxDocs > 0 && int64(reindex.MaxDocs) < want {
+		want = int64(reindex.MaxDocs)
+	}
+
+	got, err := c.count(ctx, dest, nil)
+	if err != nil {
+		return err
+	}
+
+	// query matching zero docs must not put an empty index live.
+	if got == 0 {
+		total, err := c.count(ctx, reindex.Source, nil)
+		if err != nil {
+			return err
+		}
+		if total > 0 {
+			return fmt.Errorf("verify reindex (%s -> %s): dest is empty while source has %v docs", reindex.Source, dest, total)
+		}
+	}
+
+	if !withinTolerance(want, got, v.Tolerance) {
+		return fmt.Errorf("verify reindex (%s -> %s): count %v, want %v (tolerance=%v)", reindex.Source, dest, got, want, v.Tolerance)
+	}
+
+	if v.SampleSize == 0 {
+		c.logf("[verified] index: %v (%v docs)\n", dest, got)
+		return nil
+	}
+
+	ids, err := c.sampleIDs(ctx, reindex.Source, reindex.Query, v.SampleSize)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		c.logf("[verified] index: %v (%v docs)\n", dest, got)
+		return nil
+	}
+	found, err := c.count(ctx, dest, map[string]interface{}{
+		"ids": map[string]interface{}{"values": ids},
+	})
+	if err != nil {
+		return err
+	}
+	if found != int64(len(ids)) {
+		return fmt.Errorf("verify reindex (%s -> %s): %v of %v sampled documents are missing", reindex.Source, dest, int64(len(ids))-found, len(ids))
+	}
+	c.logf("[verified] index: %v (%v docs, %v samples)\n", dest, got, len(ids))
+	return nil
+}
+
+func (c *esclient) recordVerify(ctx context.Context, dest, result string) error {
+	t, err := c.tracking(ctx, dest)
+	if err != nil {
+		return err
+	}
+	t.Verify = result
+	err = c.putTrackingMeta(ctx, dest, &t)
+	if err != nil {
+		return fmt.Errorf("record verify result of %v: %w", dest, err)
+	}
+	return nil
+}
+
+// verifyAndRecord verifies dest and records the result in _meta of dest.
+func (c *esclient) verifyAndRecord(ctx context.Context, dest string, reindex reindex) error {
+	verr := c.verifyReindex(ctx, dest, reindex)
+	result := verifyPassed
+	if verr != nil {
+		result = verifyFailed
+	}
+	err := c.recordVerify(ctx, dest, result)
+	if verr != nil {
+		return verr
+	}
+	return err
+}
+
+// reindexAndVerify reindexes into dest and verifies it.
+// pending is recorded before reindex, so the verification is retried by next run
+// even if this run stops before it passes.
+func (c *esclient) reindexAndVerify(ctx context.Context, dest string, reindex reindex) error {
+	if reindex.Verify != nil {
+		err := c.recordVerify(ctx, dest, verifyPending)
+		if err != nil {
+			return err
+		}
+	}
+	err := c.reindex(ctx, dest, reindex)
+	if err != nil {
+		return fmt.Errorf("reindex (%s -> %s): %w", reindex.Source, dest, err)
+	}
+	if reindex.Verify == nil {
+		return nil
+	}
+	return c.verifyAndRecord(ctx, dest, reindex)
+}
+
+// retryVerify verifies dest again when previous run did not pass the verification.
+// Existing index without record is treated as verified.
+func (c *esclient) retryVerify(ctx context.Context, dest string, reindex reindex) error {
+	if reindex.Verify == nil {
+		return nil
+	}
+	t, err := c.tracking(ctx, dest)
+	if err != nil {
+		return err
+	}
+	if t.Verify != verifyPending && t.Verify != verifyFailed {
+		return nil
+	}
+	c.logf("[retry] verify index: %v (previous result: %v)\n", dest, t.Verify)
+	return c.verifyAndRecord(ctx, dest, reindex)
+}
@@ -0,0 +1,193 @@
+package eskeeper
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithinTolerance(t *testing.T) {
+	tests := []struct {
+		name      string
+		want      int64
+		got       int64
+		tolerance float64
+		ok        bool
+	}{
+		{name: "same", want: 100, got: 100, ok: true},
+		{name: "less", want: 100, got: 99, ok: false},
+		{name: "within-tolerance", want: 100, got: 99, tolerance: 0.01, ok: true},
+		{name: "over-tolerance", want: 100, got: 97, tolerance: 0.01, ok: false},
+		{name: "both-empty", want: 0, got: 0, ok: true},
+		{name: "empty-dest", want: 1, got: 0, tolerance: 1, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok := withinTolerance(tt.want, tt.got, tt.tolerance); ok != tt.ok {
+				t.Errorf("want: %v, got: %v", tt.ok, ok)
+			}
+		})
+	}
+}
+
+func TestVerifyReindex(t *testing.T) {
+	tests := []struct {
+		name    string
+		dest    string
+		reindex reindex
+		setup   func(tb testing.TB)
+		cleanup func(tb testing.TB)
+		wantErr bool
+	}{
+		{
+			name: "verified",
+			dest: "verify-dest",
+			reindex: reindex{
+				Source:            "verify-src",
+				WaitForCompletion: true,
+				Verify:            &verify{SampleSize: 10},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "verify-src")
+				createTmpIndexHelper(tb, "verify-dest")
+				postDocHelper(tb, "verify-src")
+				postDocHelper(tb, "verify-src")
+			},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"verify-src", "verify-dest"})
+			},
+		},
+		{
+			name: "query-matched-zero-docs",
+			dest: "verify-zero-dest",
+			reindex: reindex{
+				Source:            "verify-zero-src",
+				WaitForCompletion: true,
+				Query: map[string]interface{}{
+					"term": map[string]interface{}{"title": "unknown"},
+				},
+				Verify: &verify{Tolerance: 1},
+			},
+			setup: func(tb testing.TB) {
+				createTmpIndexHelper(tb, "verify-zero-src")
+				createTmpIndexHelper(tb, "verify-zero-dest")
+				postDocHelper(tb, "verify-zero-src")
+			},
+			cleanup: func(tb testing.TB) {
+				deleteIndexHelper(tb, []string{"verify-zero-src", "verify-zero-dest"})
+			},
+			wantErr: true,
+		},
+	}
+
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			err := es.reindex(ctx, tt.dest, tt.reindex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = es.verifyReindex(ctx, tt.dest, tt.reindex)
+			if tt.wantErr && err == nil {
+				t.Error("expect error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Error(err)
+			}
+			if tt.cleanup != nil {
+				tt.cleanup(t)
+			}
+		})
+	}
+}
+
+func TestVerifyReindexMismatch(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	createTmpIndexHelper(t, "verify-mismatch-src")
+	createTmpIndexHelper(t, "verify-mismatch-dest")
+	postDocHelper(t, "verify-mismatch-src")
+	defer deleteIndexHelper(t, []string{"verify-mismatch-src", "verify-mismatch-dest"})
+
+	// dest is empty because reindex did not run.
+	err = es.verifyReindex(ctx, "verify-mismatch-dest", reindex{
+		Source:            "verify-mismatch-src",
+		WaitForCompletion: true,
+		Verify:            &verify{},
+	})
+	if err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestSyncIndexRetryVerify(t *testing.T) {
+	es, err := newEsClient([]string{url}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	createTmpIndexHelper(t, "verify-retry-src")
+	postDocHelper(t, "verify-retry-src")
+	createTmpIndexHelper(t, "verify-retry-dest")
+	defer deleteIndexHelper(t, []string{"verify-retry-src", "verify-retry-dest"})
+
+	ix := index{
+		Name:    "verify-retry-dest",
+		Mapping: "testdata/test.json",
+		Reindex: reindex{
+			Source:            "verify-retry-src",
+			WaitForCompletion: true,
+			On:                "firstCreated",
+			Verify:            &verify{},
+		},
+	}
+
+	// previous run created dest but failed verification.
+	err = es.recordVerify(ctx, ix.Name, verifyFailed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dest exists, so reindex is skipped, but verification is retried.
+	err = es.syncIndex(ctx, ix)
+	if err == nil {
+		t.Fatal("expect error")
+	}
+	got, err := es.tracking(ctx, ix.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Verify != verifyFailed {
+		t.Errorf("want: %v, got: %v", verifyFailed, got.Verify)
+	}
+
+	// fixed by hand.
+	err = es.reindex(ctx, ix.Name, ix.Reindex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = es.syncIndex(ctx, ix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = es.tracking(ctx, ix.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Verify != verifyPassed {
+		t.Errorf("want: %v, got: %v", verifyPassed, got.Verify)
+	}
+}